@@ -0,0 +1,98 @@
+// Package quicadmin serves the admin service of proto/admin from the QUIC
+// sessions kept by a net.Registry, so operators can list them, inspect one
+// and close the sessions of misbehaving clients. The service uses the
+// standard proto codec, it can be called by any gRPC tool given
+// proto/admin/admin.proto.
+package quicadmin
+
+import (
+	quicauth "github.com/gfanton/grpc-quic/auth"
+	qnet "github.com/gfanton/grpc-quic/net"
+	"github.com/gfanton/grpc-quic/proto/admin"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// server answers the admin RPCs from a registry.
+type server struct {
+	r *qnet.Registry
+}
+
+var _ admin.AdminServer = (*server)(nil)
+
+func newConnection(info qnet.ConnInfo) *admin.Connection {
+	c := &admin.Connection{
+		Id:            info.ID,
+		StartUnixNano: info.Start.UnixNano(),
+		Protocol:      info.Protocol,
+		NativeStreams: info.NativeStreams,
+		BytesRead:     info.BytesRead,
+		BytesWritten:  info.BytesWritten,
+		ServerName:    info.State.ServerName,
+	}
+
+	if info.LocalAddr != nil {
+		c.LocalAddr = info.LocalAddr.String()
+	}
+
+	if info.RemoteAddr != nil {
+		c.RemoteAddr = info.RemoteAddr.String()
+	}
+
+	// clients present certificates over net.VersionTLS only
+	if id, ok := quicauth.StateIdentity(info.State); ok {
+		c.PeerCertSha256 = id.CertFingerprint
+		c.PeerSubject = info.State.PeerCertificates[0].Subject.String()
+		c.PeerDnsNames = id.DNSNames
+		c.PeerUris = id.URIs
+		c.PeerVerified = id.Verified
+	}
+
+	return c
+}
+
+func (s *server) ListConnections(ctx context.Context, req *admin.ListConnectionsRequest) (*admin.ListConnectionsReply, error) {
+	infos := s.r.Connections()
+	rep := &admin.ListConnectionsReply{Connections: make([]*admin.Connection, len(infos))}
+	for i, info := range infos {
+		rep.Connections[i] = newConnection(info)
+	}
+
+	return rep, nil
+}
+
+func (s *server) GetConnection(ctx context.Context, req *admin.GetConnectionRequest) (*admin.Connection, error) {
+	info, ok := s.r.Connection(req.Id)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no connection %d", req.Id)
+	}
+
+	return newConnection(info), nil
+}
+
+func (s *server) CloseConnection(ctx context.Context, req *admin.CloseConnectionRequest) (*admin.CloseConnectionReply, error) {
+	reason := req.Reason
+	if reason == "" {
+		reason = "closed by an operator"
+	}
+
+	switch err := s.r.Close(req.Id, qnet.AdminCloseCode, reason); err {
+	case nil:
+		return &admin.CloseConnectionReply{}, nil
+	case qnet.ErrUnknownConnection:
+		return nil, status.Errorf(codes.NotFound, "no connection %d", req.Id)
+	default:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+}
+
+// Register registers on s the admin service of the sessions kept by r, to
+// be called with admin.NewAdminClient. The service lets its callers close
+// any session, so it should be guarded, for example with a quicauth
+// interceptor.
+func Register(s *grpc.Server, r *qnet.Registry) {
+	admin.RegisterAdminServer(s, &server{r: r})
+}
@@ -0,0 +1,155 @@
+// Package quicaltsvc lets a server advertise its QUIC endpoints to clients
+// connected over TCP, the way HTTP servers do with Alt-Svc. The server
+// answers a small built-in discovery RPC, and the client merges the
+// advertised udp multiaddrs into the addresses of its channel so a balancer
+// preferring UDP, such as quicbalancer, moves onto QUIC.
+package quicaltsvc
+
+import (
+	"encoding/json"
+	"fmt"
+
+	qnet "github.com/gfanton/grpc-quic/net"
+	ma "github.com/multiformats/go-multiaddr"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+)
+
+const (
+	// ServiceName is the name of the discovery service.
+	ServiceName = "grpcquic.AltSvc"
+
+	// LookupMethod is the full name of the discovery RPC.
+	LookupMethod = "/" + ServiceName + "/Lookup"
+
+	// ContentSubtype is the content-subtype of the discovery RPC, its
+	// messages are encoded in JSON so it does not depend on generated code.
+	ContentSubtype = "quic-altsvc"
+)
+
+func init() {
+	encoding.RegisterCodec(codec{})
+}
+
+type codec struct{}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (codec) Name() string {
+	return ContentSubtype
+}
+
+type lookupRequest struct{}
+
+type lookupReply struct {
+	Addrs []string `json:"addrs"`
+}
+
+// server answers the discovery RPC with a static list of multiaddrs.
+type server struct {
+	addrs []string
+}
+
+func (s *server) lookup(ctx context.Context, req *lookupRequest) (*lookupReply, error) {
+	return &lookupReply{Addrs: s.addrs}, nil
+}
+
+type lookupServer interface {
+	lookup(context.Context, *lookupRequest) (*lookupReply, error)
+}
+
+func lookupHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(lookupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+
+	if interceptor == nil {
+		return srv.(lookupServer).lookup(ctx, in)
+	}
+
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LookupMethod,
+	}
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(lookupServer).lookup(ctx, req.(*lookupRequest))
+	}
+
+	return interceptor(ctx, in, info, handler)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*lookupServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Lookup",
+			Handler:    lookupHandler,
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// Register registers on s the discovery service advertising addrs, which
+// must be udp multiaddrs.
+func Register(s *grpc.Server, addrs ...ma.Multiaddr) error {
+	list := make([]string, len(addrs))
+	for i, m := range addrs {
+		if err := checkUDP(m); err != nil {
+			return err
+		}
+
+		list[i] = m.String()
+	}
+
+	s.RegisterService(&serviceDesc, &server{addrs: list})
+	return nil
+}
+
+// Lookup asks the server cc is connected to for its QUIC endpoints.
+func Lookup(ctx context.Context, cc *grpc.ClientConn, opts ...grpc.CallOption) ([]ma.Multiaddr, error) {
+	opts = append(opts, grpc.CallContentSubtype(ContentSubtype))
+
+	rep := new(lookupReply)
+	if err := cc.Invoke(ctx, LookupMethod, &lookupRequest{}, rep, opts...); err != nil {
+		return nil, err
+	}
+
+	// skip anything which is not an udp multiaddr, this is data received
+	// from the network, and drop its certhash: the endpoints must be
+	// valid for the origin rather than pinned by it
+	var addrs []ma.Multiaddr
+	for _, s := range rep.Addrs {
+		m, err := ma.NewMultiaddr(s)
+		if err != nil || checkUDP(m) != nil {
+			continue
+		}
+
+		addrs = append(addrs, qnet.WithoutCertHash(m))
+	}
+
+	return addrs, nil
+}
+
+func checkUDP(m ma.Multiaddr) error {
+	_, protocol, err := qnet.ParseMultiaddr(m)
+	if err != nil {
+		return err
+	}
+
+	if protocol != ma.P_UDP {
+		return fmt.Errorf("`%s` is not an udp multiaddr", m)
+	}
+
+	return nil
+}
@@ -0,0 +1,251 @@
+package quicaltsvc
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gfanton/grpc-quic/transports"
+	ma "github.com/multiformats/go-multiaddr"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/grpclog"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/status"
+)
+
+// Scheme is the scheme of the resolver wrapping a target to add the QUIC
+// endpoints advertised by its servers.
+const Scheme = "altsvc"
+
+// lookupTimeout bounds a single discovery RPC.
+const lookupTimeout = 10 * time.Second
+
+var upgradeBuilder = &upgradeResolverBuilder{
+	upgrades: make(map[string]*Upgrade),
+}
+
+func init() {
+	resolver.Register(upgradeBuilder)
+}
+
+// Upgrade adds the QUIC endpoints advertised by the servers of a target to
+// the addresses it resolves to.
+type Upgrade struct {
+	id     string
+	target string
+
+	mu         sync.Mutex
+	cc         resolver.ClientConn
+	addrs      []resolver.Address
+	discovered []resolver.Address
+	origins    map[string]string
+}
+
+// NewUpgrade returns the upgrade of target. Its Target must be dialed once,
+// and Discover started with the resulting ClientConn.
+func NewUpgrade(target string) *Upgrade {
+	u := &Upgrade{target: target, origins: make(map[string]string)}
+	upgradeBuilder.register(u)
+	return u
+}
+
+// Target returns the gRPC target to dial.
+func (u *Upgrade) Target() string {
+	return Scheme + "://" + u.id + "/" + u.target
+}
+
+// Release releases an upgrade whose target was never dialed.
+func (u *Upgrade) Release() {
+	upgradeBuilder.take(u.id)
+}
+
+// Discover asks the servers of cc for their QUIC endpoints once cc is ready,
+// and retries every time cc gets ready again until it succeeds. It returns
+// when cc is closed or the server does not advertise anything.
+func (u *Upgrade) Discover(cc *grpc.ClientConn) {
+	ctx := context.Background()
+	for {
+		state := cc.GetState()
+		for state != connectivity.Ready {
+			if state == connectivity.Shutdown {
+				return
+			}
+
+			cc.WaitForStateChange(ctx, state)
+			state = cc.GetState()
+		}
+
+		var p peer.Peer
+		lctx, cancel := context.WithTimeout(ctx, lookupTimeout)
+		addrs, err := Lookup(lctx, cc, grpc.Peer(&p))
+		cancel()
+
+		switch {
+		case err == nil:
+			u.add(addrs, originHost(&p))
+			return
+		case status.Code(err) == codes.Unimplemented:
+			grpclog.Infof("quicaltsvc: `%s` does not advertise QUIC endpoints", u.target)
+			return
+		}
+
+		grpclog.Warningf("quicaltsvc: failed to look up QUIC endpoints of `%s`: %v", u.target, err)
+		cc.WaitForStateChange(ctx, connectivity.Ready)
+	}
+}
+
+// ServerName returns the host of the origin which advertised addr, the
+// certificate of a QUIC server at addr must be valid for it rather than for
+// the host of addr. It is empty if addr was not advertised, or if the origin
+// was not authenticated.
+func (u *Upgrade) ServerName(addr string) string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return u.origins[addr]
+}
+
+// add adds the endpoints advertised by the origin host.
+func (u *Upgrade) add(addrs []ma.Multiaddr, host string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for _, m := range addrs {
+		a := resolver.Address{Addr: m.String(), ServerName: host}
+		u.discovered = append(u.discovered, a)
+		if host != "" {
+			u.origins[a.Addr] = host
+		}
+	}
+
+	u.update()
+}
+
+// originHost returns the host the server answering a discovery RPC was
+// authenticated for: the server name sent over TLS or, for servers dialed
+// by IP, the IP. It is empty for insecure connections.
+func originHost(p *peer.Peer) string {
+	state, ok := transports.TLSState(p.AuthInfo)
+	if !ok {
+		return ""
+	}
+
+	if state.ServerName != "" {
+		return state.ServerName
+	}
+
+	if p.Addr == nil {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return ""
+	}
+
+	return host
+}
+
+// setResolved sets the addresses resolved by the wrapped resolver.
+func (u *Upgrade) setResolved(cc resolver.ClientConn, addrs []resolver.Address) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.cc, u.addrs = cc, addrs
+	u.update()
+}
+
+// update sends the resolved and discovered addresses to the ClientConn.
+// u.mu must be held.
+func (u *Upgrade) update() {
+	if u.cc == nil {
+		return
+	}
+
+	seen := make(map[string]bool, len(u.addrs)+len(u.discovered))
+	addrs := make([]resolver.Address, 0, len(u.addrs)+len(u.discovered))
+	for _, list := range [][]resolver.Address{u.addrs, u.discovered} {
+		for _, a := range list {
+			if !seen[a.Addr] {
+				seen[a.Addr] = true
+				addrs = append(addrs, a)
+			}
+		}
+	}
+
+	u.cc.NewAddress(addrs)
+}
+
+type upgradeResolverBuilder struct {
+	mu       sync.Mutex
+	upgrades map[string]*Upgrade
+	nextID   uint64
+}
+
+func (b *upgradeResolverBuilder) register(u *Upgrade) {
+	u.id = strconv.FormatUint(atomic.AddUint64(&b.nextID, 1), 10)
+
+	b.mu.Lock()
+	b.upgrades[u.id] = u
+	b.mu.Unlock()
+}
+
+func (b *upgradeResolverBuilder) take(id string) *Upgrade {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	u := b.upgrades[id]
+	delete(b.upgrades, id)
+	return u
+}
+
+func (b *upgradeResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOption) (resolver.Resolver, error) {
+	u := b.take(target.Authority)
+	if u == nil {
+		return nil, fmt.Errorf("unknown altsvc target `%s`", target.Authority)
+	}
+
+	inner, rb := parseTarget(target.Endpoint)
+	return rb.Build(inner, &upgradeClientConn{ClientConn: cc, u: u}, opts)
+}
+
+func (b *upgradeResolverBuilder) Scheme() string {
+	return Scheme
+}
+
+// parseTarget parses the wrapped target the same way gRPC does, targets
+// without a registered scheme are resolved by the default resolver.
+func parseTarget(target string) (resolver.Target, resolver.Builder) {
+	if i := strings.Index(target, "://"); i > 0 {
+		scheme, rest := target[:i], target[i+3:]
+		if j := strings.IndexByte(rest, '/'); j >= 0 {
+			if rb := resolver.Get(scheme); rb != nil {
+				return resolver.Target{Scheme: scheme, Authority: rest[:j], Endpoint: rest[j+1:]}, rb
+			}
+		}
+	}
+
+	scheme := resolver.GetDefaultScheme()
+	return resolver.Target{Scheme: scheme, Endpoint: target}, resolver.Get(scheme)
+}
+
+// upgradeClientConn merges the addresses of the wrapped resolver with the
+// discovered ones.
+type upgradeClientConn struct {
+	resolver.ClientConn
+
+	u *Upgrade
+}
+
+func (cc *upgradeClientConn) NewAddress(addrs []resolver.Address) {
+	cc.u.setResolved(cc.ClientConn, addrs)
+}
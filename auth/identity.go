@@ -0,0 +1,101 @@
+// Package quicauth authorizes gRPC calls from the identity found in the
+// certificate of the peer. gQUIC does not carry client certificates, QUIC
+// peers can only be identified over net.VersionTLS, see opts.TLSConfig.
+package quicauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"strings"
+
+	"github.com/gfanton/grpc-quic/transports"
+	"google.golang.org/grpc/peer"
+)
+
+// Identity is the identity of a peer, read from its certificate.
+type Identity struct {
+	// DNSNames are the DNS subject alternative names of the certificate.
+	DNSNames []string
+
+	// URIs are the URI subject alternative names of the certificate, among
+	// which SPIFFE IDs.
+	URIs []string
+
+	// CertFingerprint and KeyFingerprint are the hex encoded SHA-256 of the
+	// certificate and of its subject public key info.
+	CertFingerprint string
+	KeyFingerprint  string
+
+	// Verified is true if the certificate was verified against the client
+	// CAs of the server. The names of unverified certificates are chosen by
+	// the peer, only their fingerprints can be trusted.
+	Verified bool
+}
+
+// NewIdentity returns the unverified identity of cert.
+func NewIdentity(cert *x509.Certificate) *Identity {
+	certSum := sha256.Sum256(cert.Raw)
+	keySum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+
+	id := &Identity{
+		DNSNames:        cert.DNSNames,
+		CertFingerprint: hex.EncodeToString(certSum[:]),
+		KeyFingerprint:  hex.EncodeToString(keySum[:]),
+	}
+
+	for _, u := range cert.URIs {
+		id.URIs = append(id.URIs, u.String())
+	}
+
+	return id
+}
+
+// SPIFFEIDs returns the SPIFFE IDs of id.
+func (id *Identity) SPIFFEIDs() []string {
+	var ids []string
+	for _, u := range id.URIs {
+		if strings.HasPrefix(u, "spiffe://") {
+			ids = append(ids, u)
+		}
+	}
+
+	return ids
+}
+
+// PeerIdentity returns the identity of the peer of the call of ctx, ok is
+// false if the peer did not present any certificate. See StateIdentity.
+func PeerIdentity(ctx context.Context) (id *Identity, ok bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+
+	state, ok := transports.TLSState(p.AuthInfo)
+	if !ok {
+		return nil, false
+	}
+
+	return StateIdentity(state)
+}
+
+// StateIdentity returns the identity of the peer of a connection from its
+// TLS state, ok is false if the peer did not present any certificate. The
+// identity is read from the leaf of the verified chain if the server
+// verified the certificate, such as with tls.RequireAndVerifyClientCert, and
+// is unverified otherwise.
+func StateIdentity(state tls.ConnectionState) (id *Identity, ok bool) {
+	if len(state.VerifiedChains) > 0 && len(state.VerifiedChains[0]) > 0 {
+		id := NewIdentity(state.VerifiedChains[0][0])
+		id.Verified = true
+		return id, true
+	}
+
+	if len(state.PeerCertificates) == 0 {
+		return nil, false
+	}
+
+	return NewIdentity(state.PeerCertificates[0]), true
+}
@@ -0,0 +1,249 @@
+package quicauth
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+
+	qnet "github.com/gfanton/grpc-quic/net"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AnyPeer is the principal allowing every peer, even without a certificate.
+const AnyPeer = "*"
+
+// Rule allows the principals of Allow to call the methods matching Method.
+//
+// Method is either a full method name such as "/helloworld.Greeter/SayHello",
+// all the methods of a service such as "/helloworld.Greeter/*", or "*" for
+// every method.
+//
+// A principal is one of:
+//   - "*", any peer, even without a certificate
+//   - "dns:<name>", a DNS name of the certificate, "dns:*.example.com"
+//     matching a single label
+//   - "spiffe://<trust domain>/<path>", a SPIFFE ID of the certificate
+//   - "uri:<uri>", any URI of the certificate
+//   - "sha256:<hex>", the SHA-256 fingerprint of the certificate or of its
+//     public key
+//
+// The dns:, spiffe:// and uri: principals only match verified certificates,
+// see Identity.Verified, while sha256: pins match any certificate.
+type Rule struct {
+	Method string   `json:"method"`
+	Allow  []string `json:"allow"`
+}
+
+// Policy is a list of rules. The first rule matching the method of a call
+// decides whether it is allowed, calls to methods without any rule are
+// denied.
+type Policy struct {
+	Rules []Rule `json:"rules"`
+}
+
+// ParsePolicy parses and checks a JSON policy, such as:
+//
+//	{"rules": [
+//		{"method": "/helloworld.Greeter/SayHello", "allow": ["dns:*.example.com"]},
+//		{"method": "*", "allow": ["spiffe://example.com/admin"]}
+//	]}
+func ParsePolicy(data []byte) (*Policy, error) {
+	p := new(Policy)
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, err
+	}
+
+	if err := p.Check(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// LoadPolicy reads and parses the JSON policy in the file at path.
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParsePolicy(data)
+}
+
+// Check returns an error if a rule of p is malformed.
+func (p *Policy) Check() error {
+	for _, r := range p.Rules {
+		if r.Method != "*" && !strings.HasPrefix(r.Method, "/") {
+			return fmt.Errorf("invalid method `%s`", r.Method)
+		}
+
+		for _, principal := range r.Allow {
+			if err := checkPrincipal(principal); err != nil {
+				return fmt.Errorf("rule `%s`: %v", r.Method, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func checkPrincipal(principal string) error {
+	switch {
+	case principal == AnyPeer:
+	case strings.HasPrefix(principal, "dns:") && len(principal) > len("dns:"):
+	case strings.HasPrefix(principal, "spiffe://"):
+	case strings.HasPrefix(principal, "uri:") && len(principal) > len("uri:"):
+	case strings.HasPrefix(principal, "sha256:"):
+		if sum, err := hex.DecodeString(principal[len("sha256:"):]); err != nil || len(sum) != 32 {
+			return fmt.Errorf("invalid fingerprint `%s`", principal)
+		}
+	default:
+		return fmt.Errorf("invalid principal `%s`", principal)
+	}
+
+	return nil
+}
+
+// Authorize returns nil if the peer identified by id may call method, and a
+// PermissionDenied error otherwise. id is nil for peers without a
+// certificate.
+func (p *Policy) Authorize(method string, id *Identity) error {
+	for _, r := range p.Rules {
+		if !matchMethod(r.Method, method) {
+			continue
+		}
+
+		for _, principal := range r.Allow {
+			if matchPrincipal(principal, id) {
+				return nil
+			}
+		}
+
+		break
+	}
+
+	return status.Errorf(codes.PermissionDenied, "peer is not allowed to call %s", method)
+}
+
+// Admission returns an admission hook closing the QUIC sessions of the peers
+// p does not allow to call any method with net.AuthFailureCode, so they are
+// dropped before opening any stream. The calls of the admitted peers must
+// still be authorized by the interceptors of p.
+func (p *Policy) Admission() qnet.AdmissionFunc {
+	return func(remote net.Addr, state tls.ConnectionState) error {
+		id, _ := StateIdentity(state)
+		for _, r := range p.Rules {
+			for _, principal := range r.Allow {
+				if matchPrincipal(principal, id) {
+					return nil
+				}
+			}
+		}
+
+		return qnet.Reject(qnet.AuthFailureCode, "peer is not allowed to call any method")
+	}
+}
+
+func matchMethod(pattern, method string) bool {
+	switch {
+	case pattern == "*":
+		return true
+	case strings.HasSuffix(pattern, "/*"):
+		return strings.HasPrefix(method, pattern[:len(pattern)-1])
+	}
+
+	return pattern == method
+}
+
+func matchPrincipal(principal string, id *Identity) bool {
+	if principal == AnyPeer {
+		return true
+	}
+
+	if id == nil {
+		return false
+	}
+
+	if strings.HasPrefix(principal, "sha256:") {
+		sum := strings.ToLower(principal[len("sha256:"):])
+		return sum == id.CertFingerprint || sum == id.KeyFingerprint
+	}
+
+	// the names of unverified certificates are chosen by the peer
+	if !id.Verified {
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(principal, "dns:"):
+		for _, name := range id.DNSNames {
+			if matchDNS(principal[len("dns:"):], name) {
+				return true
+			}
+		}
+	case strings.HasPrefix(principal, "spiffe://"):
+		for _, u := range id.SPIFFEIDs() {
+			if u == principal {
+				return true
+			}
+		}
+	case strings.HasPrefix(principal, "uri:"):
+		for _, u := range id.URIs {
+			if u == principal[len("uri:"):] {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// matchDNS matches name against pattern, a leading "*." of pattern matching
+// a single label.
+func matchDNS(pattern, name string) bool {
+	pattern = strings.ToLower(strings.TrimSuffix(pattern, "."))
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+
+	if strings.HasPrefix(pattern, "*.") {
+		i := strings.IndexByte(name, '.')
+		return i > 0 && name[i:] == pattern[1:]
+	}
+
+	return pattern == name
+}
+
+// UnaryServerInterceptor returns an interceptor denying the unary calls p
+// does not allow with PermissionDenied.
+func UnaryServerInterceptor(p *Policy) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := authorize(ctx, p, info.FullMethod); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns an interceptor denying the streaming calls
+// p does not allow with PermissionDenied.
+func StreamServerInterceptor(p *Policy) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := authorize(ss.Context(), p, info.FullMethod); err != nil {
+			return err
+		}
+
+		return handler(srv, ss)
+	}
+}
+
+func authorize(ctx context.Context, p *Policy, method string) error {
+	id, _ := PeerIdentity(ctx)
+	return p.Authorize(method, id)
+}
@@ -0,0 +1,475 @@
+package quicbalancer
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	qnet "github.com/gfanton/grpc-quic/net"
+	ma "github.com/multiformats/go-multiaddr"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/grpclog"
+	"google.golang.org/grpc/resolver"
+)
+
+// BreakerName is the name of the breaker balancer, which demotes every UDP
+// subconn for a while when QUIC keeps failing.
+const BreakerName = "quic_breaker_balancer"
+
+const (
+	// DefaultBreakerThreshold is the default number of QUIC failures within
+	// the breaker window that demotes the UDP tier.
+	DefaultBreakerThreshold = 5
+
+	// DefaultBreakerWindow is the default duration over which QUIC failures
+	// are counted.
+	DefaultBreakerWindow = 10 * time.Second
+
+	// DefaultBreakerCooldown is the default duration the UDP tier stays
+	// demoted before QUIC is probed again.
+	DefaultBreakerCooldown = 30 * time.Second
+
+	// DefaultBreakerProbeTimeout is the default time a probe of the UDP
+	// tier has to connect and get an answer.
+	DefaultBreakerProbeTimeout = 5 * time.Second
+)
+
+// probeMethod is the method called by the probes of the UDP tier. No server
+// implements it: the Unimplemented status it gets back proves QUIC carries
+// RPCs again, without running any handler.
+const probeMethod = "/grpcquic.Breaker/Probe"
+
+// BreakerConfig configures the breaker balancer.
+type BreakerConfig struct {
+	// Threshold is the number of QUIC handshake and RPC failures within
+	// Window that demotes the UDP tier.
+	Threshold int
+
+	// Window is the duration over which failures are counted.
+	Window time.Duration
+
+	// Cooldown is the duration the UDP tier stays demoted, all RPCs are
+	// sent over TCP meanwhile.
+	Cooldown time.Duration
+
+	// ProbeTimeout bounds a probe of the UDP tier once the cooldown is
+	// over.
+	ProbeTimeout time.Duration
+}
+
+func (cfg *BreakerConfig) withDefaults() BreakerConfig {
+	c := BreakerConfig{
+		Threshold:    DefaultBreakerThreshold,
+		Window:       DefaultBreakerWindow,
+		Cooldown:     DefaultBreakerCooldown,
+		ProbeTimeout: DefaultBreakerProbeTimeout,
+	}
+
+	if cfg == nil {
+		return c
+	}
+
+	if cfg.Threshold > 0 {
+		c.Threshold = cfg.Threshold
+	}
+
+	if cfg.Window > 0 {
+		c.Window = cfg.Window
+	}
+
+	if cfg.Cooldown > 0 {
+		c.Cooldown = cfg.Cooldown
+	}
+
+	if cfg.ProbeTimeout > 0 {
+		c.ProbeTimeout = cfg.ProbeTimeout
+	}
+
+	return c
+}
+
+// NewBreakerBuilder creates a balancer builder named name, which picks UDP
+// subconns before TCP ones like the quic balancer, but tracks QUIC
+// handshake and RPC failures as a group. Past cfg.Threshold failures the
+// whole UDP tier is demoted for cfg.Cooldown and RPCs are sent over TCP.
+// Once the cooldown is over, QUIC is promoted back as soon as a UDP subconn
+// reconnects in the background or a probe succeeds: the probe dials a UDP
+// address of the tier on its own connection, with the dialer and the
+// credentials of the ClientConn, and calls a method no server implements.
+// A probe the dialer connects over TCP fails, such as with happy eyeballs.
+// RPCs of the application are never used as probes. A nil cfg uses the
+// defaults.
+func NewBreakerBuilder(name string, cfg *BreakerConfig) balancer.Builder {
+	return &breakerBuilder{name: name, cfg: cfg.withDefaults()}
+}
+
+type breakerBuilder struct {
+	name string
+	cfg  BreakerConfig
+}
+
+func (b *breakerBuilder) Build(cc balancer.ClientConn, opts balancer.BuildOptions) balancer.Balancer {
+	bcc := &breakerClientConn{
+		ClientConn: cc,
+		addrs:      make(map[balancer.SubConn]resolver.Address),
+	}
+
+	br := &breaker{
+		cfg:    b.cfg,
+		target: cc.Target(),
+		cc:     bcc,
+		dialer: opts.Dialer,
+		creds:  opts.DialCreds,
+	}
+
+	return &breakerBalancer{
+		Balancer: base.NewBalancerBuilder(b.name, &breakerPickerBuilder{br}).Build(bcc, opts),
+		cc:       bcc,
+		br:       br,
+	}
+}
+
+func (b *breakerBuilder) Name() string {
+	return b.name
+}
+
+// breakerClientConn records the address of the subconns created by the
+// base balancer.
+type breakerClientConn struct {
+	balancer.ClientConn
+
+	mu    sync.Mutex
+	addrs map[balancer.SubConn]resolver.Address
+}
+
+func (cc *breakerClientConn) NewSubConn(addrs []resolver.Address, opts balancer.NewSubConnOptions) (balancer.SubConn, error) {
+	sc, err := cc.ClientConn.NewSubConn(addrs, opts)
+	if err != nil || len(addrs) == 0 {
+		return sc, err
+	}
+
+	cc.mu.Lock()
+	cc.addrs[sc] = addrs[0]
+	cc.mu.Unlock()
+
+	return sc, nil
+}
+
+func (cc *breakerClientConn) protocolOf(sc balancer.SubConn, remove bool) int {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	a, ok := cc.addrs[sc]
+	if remove {
+		delete(cc.addrs, sc)
+	}
+
+	if !ok {
+		return 0
+	}
+
+	protocol, err := protocolOf(a)
+	if err != nil {
+		return 0
+	}
+
+	return protocol
+}
+
+// udpAddr returns the address of a UDP subconn, ok is false if there is
+// none.
+func (cc *breakerClientConn) udpAddr() (a resolver.Address, ok bool) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	for _, a := range cc.addrs {
+		if protocol, err := protocolOf(a); err == nil && protocol == ma.P_UDP {
+			return a, true
+		}
+	}
+
+	return resolver.Address{}, false
+}
+
+// breakerBalancer feeds the breaker with the state changes of UDP subconns.
+type breakerBalancer struct {
+	balancer.Balancer
+
+	cc *breakerClientConn
+	br *breaker
+}
+
+func (b *breakerBalancer) HandleSubConnStateChange(sc balancer.SubConn, s connectivity.State) {
+	if b.cc.protocolOf(sc, s == connectivity.Shutdown) == ma.P_UDP {
+		switch s {
+		case connectivity.TransientFailure:
+			b.br.failure()
+		case connectivity.Ready:
+			b.br.ready()
+		}
+	}
+
+	b.Balancer.HandleSubConnStateChange(sc, s)
+}
+
+func (b *breakerBalancer) Close() {
+	b.br.stop()
+	b.Balancer.Close()
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerProbing
+)
+
+// breaker is the circuit breaker of the UDP tier.
+type breaker struct {
+	cfg    BreakerConfig
+	target string
+	cc     *breakerClientConn
+	dialer func(context.Context, string) (net.Conn, error)
+	creds  credentials.TransportCredentials
+
+	mu       sync.Mutex
+	state    breakerState
+	failures []time.Time
+	openedAt time.Time
+	timer    *time.Timer
+	stopped  bool
+}
+
+// allow reports whether an RPC may be sent over UDP.
+func (br *breaker) allow() bool {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	return br.state == breakerClosed
+}
+
+// failure records a QUIC handshake or RPC failure.
+func (br *breaker) failure() {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	now := time.Now()
+	switch br.state {
+	case breakerClosed:
+		failures := br.failures[:0]
+		for _, t := range br.failures {
+			if now.Sub(t) < br.cfg.Window {
+				failures = append(failures, t)
+			}
+		}
+		br.failures = append(failures, now)
+
+		if len(br.failures) >= br.cfg.Threshold {
+			grpclog.Warningf("quicbalancer: %d QUIC failures within %s, demoting UDP for %s on %s",
+				len(br.failures), br.cfg.Window, br.cfg.Cooldown, br.target)
+			br.open(now)
+		}
+	case breakerProbing:
+		grpclog.Warningf("quicbalancer: UDP subconn failed while probing, demoting UDP for %s on %s", br.cfg.Cooldown, br.target)
+		br.open(now)
+	}
+}
+
+// ready records a UDP subconn which completed its handshake.
+func (br *breaker) ready() {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	if br.state == breakerOpen && time.Since(br.openedAt) >= br.cfg.Cooldown {
+		br.state = breakerProbing
+	}
+
+	if br.state == breakerProbing {
+		br.close()
+	}
+}
+
+// open demotes the UDP tier and schedules a probe once the cooldown is over.
+func (br *breaker) open(now time.Time) {
+	br.state = breakerOpen
+	br.openedAt = now
+	br.failures = br.failures[:0]
+
+	if br.timer != nil {
+		br.timer.Stop()
+	}
+
+	if !br.stopped {
+		br.timer = time.AfterFunc(br.cfg.Cooldown, br.probe)
+	}
+}
+
+func (br *breaker) close() {
+	grpclog.Infof("quicbalancer: promoting UDP back on %s", br.target)
+	br.state = breakerClosed
+	br.failures = br.failures[:0]
+}
+
+func (br *breaker) stop() {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	br.stopped = true
+	if br.timer != nil {
+		br.timer.Stop()
+	}
+}
+
+// probe checks in the background whether QUIC carries RPCs again, and
+// promotes the UDP tier back if it does.
+func (br *breaker) probe() {
+	br.mu.Lock()
+	if br.state != breakerOpen || br.stopped {
+		br.mu.Unlock()
+		return
+	}
+
+	br.state = breakerProbing
+	br.mu.Unlock()
+
+	grpclog.Infof("quicbalancer: probing QUIC again for %s", br.target)
+
+	var err error
+	if a, ok := br.cc.udpAddr(); ok {
+		err = br.sendProbe(a)
+	} else {
+		err = errNoUDPSubConn
+	}
+
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	// a UDP subconn may have failed or reconnected meanwhile
+	if br.state != breakerProbing {
+		return
+	}
+
+	if err != nil {
+		grpclog.Warningf("quicbalancer: QUIC probe failed, demoting UDP for %s on %s: %v", br.cfg.Cooldown, br.target, err)
+		br.open(time.Now())
+		return
+	}
+
+	br.close()
+}
+
+var errNoUDPSubConn = errors.New("no UDP subconn to probe")
+
+// errProbeNotQuic fails a probe whose connection is not carried over QUIC.
+var errProbeNotQuic error = probeError("probe did not connect over QUIC")
+
+// probeError is a permanent dial error, which makes the probe fail at once
+// rather than redial until its timeout.
+type probeError string
+
+func (e probeError) Error() string   { return string(e) }
+func (e probeError) Temporary() bool { return false }
+
+// sendProbe calls probeMethod on a new QUIC connection to a, and returns an
+// error if the call fails because of the endpoint or the path to it, or if
+// the dialer connected over TCP.
+func (br *breaker) sendProbe(a resolver.Address) error {
+	ctx, cancel := context.WithTimeout(context.Background(), br.cfg.ProbeTimeout)
+	defer cancel()
+
+	opts := []grpc.DialOption{grpc.WithBlock()}
+	if br.creds != nil {
+		opts = append(opts, grpc.WithTransportCredentials(br.creds))
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+
+	if br.dialer != nil {
+		opts = append(opts, grpc.FailOnNonTempDialError(true), grpc.WithDialer(func(addr string, timeout time.Duration) (net.Conn, error) {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			conn, err := br.dialer(ctx, addr)
+			if err != nil {
+				return nil, err
+			}
+
+			// the dialer falls back to TCP with happy eyeballs or when
+			// QUIC is known to be blocked, which proves nothing about QUIC
+			if _, ok := conn.(qnet.SessionConn); !ok {
+				conn.Close()
+				return nil, errProbeNotQuic
+			}
+
+			return conn, nil
+		}))
+	}
+
+	cc, err := grpc.DialContext(ctx, a.Addr, opts...)
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+
+	stream, err := cc.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}, probeMethod)
+	if err == nil {
+		if err = stream.CloseSend(); err == nil {
+			err = stream.RecvMsg(&struct{}{})
+		}
+	}
+
+	if isEndpointFailure(err) {
+		return err
+	}
+
+	return nil
+}
+
+type breakerPickerBuilder struct {
+	br *breaker
+}
+
+func (b *breakerPickerBuilder) Build(readySCs map[resolver.Address]balancer.SubConn) balancer.Picker {
+	scs := splitByProtocol(readySCs)
+	return &breakerPicker{
+		br:  b.br,
+		udp: &rrPicker{subConns: scs[ma.P_UDP]},
+		tcp: &rrPicker{subConns: scs[ma.P_TCP]},
+	}
+}
+
+// breakerPicker asks the breaker on every pick, so demoting or promoting
+// the UDP tier does not require a new picker.
+type breakerPicker struct {
+	br  *breaker
+	udp *rrPicker
+	tcp *rrPicker
+}
+
+func (p *breakerPicker) Pick(ctx context.Context, opts balancer.PickOptions) (balancer.SubConn, func(balancer.DoneInfo), error) {
+	if len(p.udp.subConns) > 0 && p.br.allow() {
+		sc, _, err := p.udp.Pick(ctx, opts)
+		done := func(info balancer.DoneInfo) {
+			if isEndpointFailure(info.Err) {
+				p.br.failure()
+			}
+		}
+
+		return sc, done, err
+	}
+
+	if len(p.tcp.subConns) > 0 {
+		return p.tcp.Pick(ctx, opts)
+	}
+
+	// no TCP subconn to fall back on, UDP is better than nothing
+	return p.udp.Pick(ctx, opts)
+}
@@ -0,0 +1,296 @@
+package quicbalancer
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	ma "github.com/multiformats/go-multiaddr"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/grpclog"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/status"
+)
+
+// LatencyName is the name of the latency balancer, which weights picks
+// toward the subconns with the lowest RPC latency and error rate.
+const LatencyName = "quic_latency_balancer"
+
+const (
+	// DefaultLatencyDecay is the default weight of a new sample in the
+	// moving averages of the latency balancer.
+	DefaultLatencyDecay = 0.2
+
+	// DefaultErrorPenalty is the default factor applied to the error rate
+	// of a subconn when weighting it.
+	DefaultErrorPenalty = 10.0
+
+	// minLatency bounds the latency of a subconn so a single very fast
+	// subconn cannot take an infinite weight.
+	minLatency = 100 * time.Microsecond
+)
+
+// DefaultProtocolBias slightly favors QUIC over TCP when both have the same
+// latency, TCP being more prone to head of line blocking under loss.
+var DefaultProtocolBias = map[int]float64{
+	ma.P_UDP: 1.0,
+	ma.P_TCP: 1.2,
+}
+
+// LatencyConfig configures the latency balancer.
+type LatencyConfig struct {
+	// Decay is the weight of a new sample in the exponentially weighted
+	// moving averages of latency and error rate, between 0 and 1.
+	Decay float64
+
+	// ErrorPenalty scales the error rate of a subconn: its latency is
+	// multiplied by 1 + ErrorPenalty * error rate.
+	ErrorPenalty float64
+
+	// ProtocolBias multiplies the latency of subconns by transport
+	// protocol code, protocols without an entry are left as is.
+	ProtocolBias map[int]float64
+}
+
+func (cfg *LatencyConfig) withDefaults() LatencyConfig {
+	c := LatencyConfig{
+		Decay:        DefaultLatencyDecay,
+		ErrorPenalty: DefaultErrorPenalty,
+		ProtocolBias: DefaultProtocolBias,
+	}
+
+	if cfg == nil {
+		return c
+	}
+
+	if cfg.Decay > 0 && cfg.Decay <= 1 {
+		c.Decay = cfg.Decay
+	}
+
+	if cfg.ErrorPenalty > 0 {
+		c.ErrorPenalty = cfg.ErrorPenalty
+	}
+
+	if cfg.ProtocolBias != nil {
+		c.ProtocolBias = cfg.ProtocolBias
+	}
+
+	return c
+}
+
+// NewLatencyBuilder creates a balancer builder named name, which measures
+// the latency and error rate of unary RPCs through the done callback of the
+// picker and picks subconns at random, weighted by the inverse of their
+// biased latency. A nil cfg uses the defaults.
+//
+// The lifetime of a stream says nothing about its subconn, so the streams
+// marked by StreamClientInterceptor are never measured. Dial installs it.
+func NewLatencyBuilder(name string, cfg *LatencyConfig) balancer.Builder {
+	return &latencyBuilder{name: name, cfg: cfg.withDefaults()}
+}
+
+type latencyBuilder struct {
+	name string
+	cfg  LatencyConfig
+}
+
+// Build creates a base balancer with its own picker builder, so the
+// measures of a ClientConn are never mixed with the ones of another.
+func (b *latencyBuilder) Build(cc balancer.ClientConn, opts balancer.BuildOptions) balancer.Balancer {
+	pb := &latencyPickerBuilder{
+		cfg:   &b.cfg,
+		stats: make(map[balancer.SubConn]*subConnStats),
+	}
+
+	return base.NewBalancerBuilder(b.name, pb).Build(cc, opts)
+}
+
+func (b *latencyBuilder) Name() string {
+	return b.name
+}
+
+// subConnStats holds the moving averages of a subconn.
+type subConnStats struct {
+	mu      sync.Mutex
+	latency float64 // seconds, 0 until the first sample
+	errRate float64
+}
+
+func (s *subConnStats) observe(decay float64, latency time.Duration, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	errSample := 0.0
+	if failed {
+		errSample = 1.0
+	}
+	s.errRate += decay * (errSample - s.errRate)
+
+	// the latency of a failed call says nothing about the endpoint
+	if failed {
+		return
+	}
+
+	if l := latency.Seconds(); s.latency == 0 {
+		s.latency = l
+	} else {
+		s.latency += decay * (l - s.latency)
+	}
+}
+
+func (s *subConnStats) get() (latency, errRate float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.latency, s.errRate
+}
+
+type latencyPickerBuilder struct {
+	cfg *LatencyConfig
+
+	mu    sync.Mutex
+	stats map[balancer.SubConn]*subConnStats
+}
+
+// Build keeps the measures of the subconns that are still ready, a subconn
+// coming back from a failure starts again without any measure.
+func (b *latencyPickerBuilder) Build(readySCs map[resolver.Address]balancer.SubConn) balancer.Picker {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := make(map[balancer.SubConn]*subConnStats, len(readySCs))
+	p := &latencyPicker{cfg: b.cfg}
+	for a, sc := range readySCs {
+		protocol, err := protocolOf(a)
+		if err != nil {
+			grpclog.Warningf("quicbalancer: ignoring subconn %s: %v", a.Addr, err)
+			continue
+		}
+
+		s, ok := b.stats[sc]
+		if !ok {
+			s = new(subConnStats)
+		}
+		stats[sc] = s
+
+		bias, ok := b.cfg.ProtocolBias[protocol]
+		if !ok || bias <= 0 {
+			bias = 1
+		}
+
+		p.subConns = append(p.subConns, latencySubConn{sc: sc, stats: s, bias: bias})
+	}
+
+	b.stats = stats
+	return p
+}
+
+type latencySubConn struct {
+	sc    balancer.SubConn
+	stats *subConnStats
+	bias  float64
+}
+
+type latencyPicker struct {
+	cfg      *LatencyConfig
+	subConns []latencySubConn
+}
+
+func (p *latencyPicker) Pick(ctx context.Context, opts balancer.PickOptions) (balancer.SubConn, func(balancer.DoneInfo), error) {
+	if len(p.subConns) <= 0 {
+		return nil, nil, balancer.ErrNoSubConnAvailable
+	}
+
+	sub := p.subConns[p.pick()]
+	if isStream(ctx) {
+		return sub.sc, nil, nil
+	}
+
+	start := time.Now()
+	done := func(info balancer.DoneInfo) {
+		sub.stats.observe(p.cfg.Decay, time.Since(start), isEndpointFailure(info.Err))
+	}
+
+	return sub.sc, done, nil
+}
+
+// pick returns the index of a subconn chosen at random, weighted by the
+// inverse of its biased latency.
+func (p *latencyPicker) pick() int {
+	latencies := make([]float64, len(p.subConns))
+	errRates := make([]float64, len(p.subConns))
+
+	// subconns without any measure are given the best known latency, so
+	// they get probed instead of being starved
+	best := 0.0
+	for i, sub := range p.subConns {
+		latencies[i], errRates[i] = sub.stats.get()
+		if latencies[i] > 0 && (best == 0 || latencies[i] < best) {
+			best = latencies[i]
+		}
+	}
+
+	if best == 0 {
+		best = minLatency.Seconds()
+	}
+
+	weights := make([]float64, len(p.subConns))
+	total := 0.0
+	for i, sub := range p.subConns {
+		latency := latencies[i]
+		if latency == 0 {
+			latency = best
+		}
+
+		if latency < minLatency.Seconds() {
+			latency = minLatency.Seconds()
+		}
+
+		score := latency * sub.bias * (1 + p.cfg.ErrorPenalty*errRates[i])
+		weights[i] = 1 / score
+		total += weights[i]
+	}
+
+	r := rand.Float64() * total
+	for i, w := range weights {
+		if r < w {
+			return i
+		}
+		r -= w
+	}
+
+	return len(weights) - 1
+}
+
+type streamKey struct{}
+
+// StreamClientInterceptor marks the context of streaming RPCs, so the
+// latency balancer does not take their lifetime for the latency of their
+// subconn. Unary RPCs do not go through stream interceptors.
+func StreamClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return streamer(context.WithValue(ctx, streamKey{}, true), desc, cc, method, opts...)
+}
+
+func isStream(ctx context.Context) bool {
+	_, ok := ctx.Value(streamKey{}).(bool)
+	return ok
+}
+
+// isEndpointFailure reports whether err is likely caused by the endpoint or
+// the path to it rather than by the application.
+func isEndpointFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Internal:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,36 @@
+package quicbalancer
+
+import (
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/resolver"
+)
+
+// PriorityName is the name of the priority balancer, which only picks TCP
+// subconns when no UDP subconn is ready.
+const PriorityName = "quic_priority_balancer"
+
+// NewPriorityBuilder creates a balancer builder named name, which picks
+// subconns by strict protocol priority: subconns of a protocol are only
+// picked when none of the protocols before it in order has a ready subconn.
+// Subconns of the same protocol are picked in round robin.
+func NewPriorityBuilder(name string, order ...int) balancer.Builder {
+	return base.NewBalancerBuilder(name, &priorityPickerBuilder{order})
+}
+
+type priorityPickerBuilder struct {
+	order []int
+}
+
+// Build is called by the base balancer every time a subconn becomes ready or
+// not ready, so the picker only has to pick within the best tier.
+func (b *priorityPickerBuilder) Build(readySCs map[resolver.Address]balancer.SubConn) balancer.Picker {
+	scs := splitByProtocol(readySCs)
+	for _, protocol := range b.order {
+		if len(scs[protocol]) > 0 {
+			return &rrPicker{subConns: scs[protocol]}
+		}
+	}
+
+	return &rrPicker{}
+}
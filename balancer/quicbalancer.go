@@ -22,63 +22,68 @@ func newBuilder() balancer.Builder {
 
 func init() {
 	balancer.Register(newBuilder())
+	balancer.Register(NewPriorityBuilder(PriorityName, ma.P_UDP, ma.P_TCP))
+	balancer.Register(NewLatencyBuilder(LatencyName, nil))
+	balancer.Register(NewBreakerBuilder(BreakerName, nil))
 }
 
-type rrPickerBuilder struct{}
+// protocolOf returns the transport protocol code of the multiaddr of a.
+func protocolOf(a resolver.Address) (int, error) {
+	m, err := ma.NewMultiaddr(a.Addr)
+	if err != nil {
+		return 0, err
+	}
 
-func (*rrPickerBuilder) Build(readySCs map[resolver.Address]balancer.SubConn) balancer.Picker {
-	var scsTCP, scsUDP []balancer.SubConn
+	_, protocol, err := qnet.ParseMultiaddr(m)
+	return protocol, err
+}
 
+// splitByProtocol splits subconns by transport protocol.
+func splitByProtocol(readySCs map[resolver.Address]balancer.SubConn) map[int][]balancer.SubConn {
+	scs := make(map[int][]balancer.SubConn)
 	for a, sc := range readySCs {
-		m, err := ma.NewMultiaddr(a.Addr)
-		if err != nil {
-			// @TODO: LOG THIS
-			continue
-		}
-
-		_, protocol, err := qnet.ParseMultiaddr(m)
+		protocol, err := protocolOf(a)
 		if err != nil {
 			// @TODO: LOG THIS
 			continue
 		}
 
-		switch protocol {
-		case ma.P_UDP:
-			scsUDP = append(scsUDP, sc)
-		case ma.P_TCP:
-			scsTCP = append(scsTCP, sc)
-		default: // @TODO: LOG THIS
-		}
+		scs[protocol] = append(scs[protocol], sc)
 	}
 
+	return scs
+}
+
+type rrPickerBuilder struct{}
+
+func (*rrPickerBuilder) Build(readySCs map[resolver.Address]balancer.SubConn) balancer.Picker {
+	scs := splitByProtocol(readySCs)
+
+	// Chain TCP subConn after UDP subconn
 	return &rrPicker{
-		subConnsUDP: scsUDP,
-		subConnsTCP: scsTCP,
+		subConns: append(scs[ma.P_UDP], scs[ma.P_TCP]...),
 	}
 }
 
 type rrPicker struct {
-	// subConnsTCP and subConnsUDP are the snapshot of the roundrobin balancer when this picker was
-	// created. The slice is immutable. Each Get() will do a round robin
-	// selection from it and return the selected SubConn.
-	subConnsTCP []balancer.SubConn
-	subConnsUDP []balancer.SubConn
+	// subConns is the snapshot of the roundrobin balancer when this picker
+	// was created, UDP subconns first. The slice is immutable. Each Get()
+	// will do a round robin selection from it and return the selected
+	// SubConn.
+	subConns []balancer.SubConn
 
 	mu   sync.Mutex
 	next int
 }
 
-// @TODO: Round robin around udp first then tcp
 func (p *rrPicker) Pick(ctx context.Context, opts balancer.PickOptions) (balancer.SubConn, func(balancer.DoneInfo), error) {
-	// Chain TCP subConn after UDP subconn
-	scs := append(p.subConnsUDP, p.subConnsTCP...)
-	if len(scs) <= 0 {
+	if len(p.subConns) <= 0 {
 		return nil, nil, balancer.ErrNoSubConnAvailable
 	}
 
 	p.mu.Lock()
-	sc := scs[p.next]
-	p.next = (p.next + 1) % len(scs)
+	sc := p.subConns[p.next]
+	p.next = (p.next + 1) % len(p.subConns)
 	p.mu.Unlock()
 	return sc, nil, nil
 }
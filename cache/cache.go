@@ -0,0 +1,98 @@
+// Package quiccache remembers, per host and network, whether QUIC could be
+// used the last time it was dialed, so new connections don't have to find
+// out again that UDP is blocked.
+package quiccache
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultTTL is the duration an outcome is remembered.
+const DefaultTTL = 5 * time.Minute
+
+// Cache records whether QUIC works for a target address. It must be safe
+// for concurrent use.
+type Cache interface {
+	// Lookup returns whether QUIC worked the last time addr was dialed on
+	// network, ok is false when unknown or expired.
+	Lookup(network, addr string) (works, ok bool)
+
+	// Store records whether QUIC worked when dialing addr on network.
+	Store(network, addr string, works bool)
+}
+
+// Entry is an outcome remembered by a cache.
+type Entry struct {
+	Works   bool      `json:"works"`
+	Expires time.Time `json:"expires"`
+}
+
+func key(network, addr string) string {
+	return network + " " + addr
+}
+
+// MemoryCache is a Cache keeping its entries in memory.
+type MemoryCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+// NewMemoryCache creates a cache remembering outcomes for ttl, DefaultTTL is
+// used if ttl is zero.
+func NewMemoryCache(ttl time.Duration) *MemoryCache {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	return &MemoryCache{
+		ttl:     ttl,
+		entries: make(map[string]Entry),
+	}
+}
+
+func (c *MemoryCache) Lookup(network, addr string) (works, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	k := key(network, addr)
+	e, ok := c.entries[k]
+	if !ok {
+		return false, false
+	}
+
+	if time.Now().After(e.Expires) {
+		delete(c.entries, k)
+		return false, false
+	}
+
+	return e.Works, true
+}
+
+func (c *MemoryCache) Store(network, addr string, works bool) {
+	c.mu.Lock()
+	c.store(network, addr, works)
+	c.mu.Unlock()
+}
+
+func (c *MemoryCache) store(network, addr string, works bool) {
+	c.entries[key(network, addr)] = Entry{
+		Works:   works,
+		Expires: time.Now().Add(c.ttl),
+	}
+}
+
+// snapshot returns the entries which are not expired. c.mu must be held.
+func (c *MemoryCache) snapshot() map[string]Entry {
+	now := time.Now()
+	entries := make(map[string]Entry, len(c.entries))
+	for k, e := range c.entries {
+		if now.Before(e.Expires) {
+			entries[k] = e
+		}
+	}
+
+	return entries
+}
@@ -0,0 +1,85 @@
+package quiccache
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"google.golang.org/grpc/grpclog"
+)
+
+// FileCache is a Cache persisting its entries in a JSON file, so they are
+// shared by successive processes.
+type FileCache struct {
+	*MemoryCache
+
+	path string
+}
+
+// NewFileCache creates a cache remembering outcomes for ttl in the file at
+// path, which is loaded if it exists. DefaultTTL is used if ttl is zero.
+func NewFileCache(path string, ttl time.Duration) (*FileCache, error) {
+	c := &FileCache{
+		MemoryCache: NewMemoryCache(ttl),
+		path:        path,
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		return nil, err
+	}
+
+	if c.entries == nil {
+		c.entries = make(map[string]Entry)
+	}
+
+	return c, nil
+}
+
+// Store records whether QUIC worked and writes the cache file. Failing to
+// write it is only logged, the entry is still remembered in memory.
+func (c *FileCache) Store(network, addr string, works bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.store(network, addr, works)
+	if err := c.write(); err != nil {
+		grpclog.Warningf("quiccache: unable to write `%s`: %v", c.path, err)
+	}
+}
+
+// write replaces the cache file atomically. c.mu must be held.
+func (c *FileCache) write() error {
+	data, err := json.Marshal(c.snapshot())
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(c.path), filepath.Base(c.path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), c.path)
+}
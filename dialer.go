@@ -0,0 +1,386 @@
+package grpcquic
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	quicmetrics "github.com/gfanton/grpc-quic/metrics"
+	qnet "github.com/gfanton/grpc-quic/net"
+	options "github.com/gfanton/grpc-quic/opts"
+	"github.com/gfanton/grpc-quic/transports"
+	quic "github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/qerr"
+	ma "github.com/multiformats/go-multiaddr"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// dialQuic dials the udp multiaddr m over QUIC. The certificate of the
+// server is verified for serverName if set, for the host of m otherwise.
+func dialQuic(ctx context.Context, cfg *options.ClientConfig, m ma.Multiaddr, serverName string) (conn net.Conn, err error) {
+	labels := dialLabels(m, quicmetrics.ProtocolQUIC)
+	defer func(start time.Time) {
+		reportDial(ctx, cfg, labels, start, err)
+		if err == nil {
+			conn = qnet.MeterConn(conn, cfg.Metrics, labels)
+		}
+	}(time.Now())
+
+	raddr, _, err := qnet.ResolveMultiaddr(ctx, cfg.DNSResolver, m)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConf, err := quicTLSConfig(cfg, m, serverName)
+	if err != nil {
+		return nil, err
+	}
+
+	sess, err := quic.DialAddrContext(ctx, raddr, tlsConf, cfg.QuicConf)
+	// an attempt canceled by the caller tells nothing about QUIC, neither
+	// does the loser of a race, which dialRace records itself, but one
+	// still pending at the dial deadline is likely blocked
+	if err == nil || !errors.Is(ctx.Err(), context.Canceled) {
+		storeQuicWorks(cfg, m, err == nil)
+	}
+
+	if err != nil {
+		return nil, qnet.WrapError(raddr, err)
+	}
+
+	// gQUIC ignores VerifyPeerCertificate, pins are checked once the
+	// handshake, which proves the server owns the certificate, is done
+	if hashes := qnet.CertHashes(m); len(hashes) > 0 && !cfg.Insecure {
+		if err := verifyCertHash(sess.ConnectionState().PeerCertificates, hashes); err != nil {
+			sess.CloseWithError(quic.ErrorCode(qerr.ProofInvalid), err)
+			return nil, err
+		}
+	}
+
+	if cfg.NativeStreams {
+		conn, err = qnet.NewStreamsConn(sess)
+	} else {
+		conn, err = qnet.NewConn(sess)
+	}
+
+	return conn, qnet.WrapError(raddr, err)
+}
+
+func dialTCP(ctx context.Context, cfg *options.ClientConfig, m ma.Multiaddr) (conn net.Conn, err error) {
+	labels := dialLabels(m, quicmetrics.ProtocolTCP)
+	defer func(start time.Time) {
+		reportDial(ctx, cfg, labels, start, err)
+		if err == nil {
+			conn = qnet.MeterConn(conn, cfg.Metrics, labels)
+		}
+	}(time.Now())
+
+	return dialTCPConn(ctx, cfg, m)
+}
+
+func dialTCPConn(ctx context.Context, cfg *options.ClientConfig, m ma.Multiaddr) (net.Conn, error) {
+	raddr, _, err := qnet.ResolveMultiaddr(ctx, cfg.DNSResolver, m)
+	if err != nil {
+		return nil, err
+	}
+
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", raddr)
+}
+
+// dialTLS dials m over TCP and completes the TLS handshake, which is
+// otherwise done by the transport credentials, verifying the certificate
+// like dialQuic. In insecure mode the connection is left in plaintext.
+func dialTLS(ctx context.Context, cfg *options.ClientConfig, m ma.Multiaddr, serverName string) (_ net.Conn, err error) {
+	if cfg.Insecure {
+		return dialTCP(ctx, cfg, m)
+	}
+
+	labels := dialLabels(m, quicmetrics.ProtocolTCP)
+	defer func(start time.Time) {
+		reportDial(ctx, cfg, labels, start, err)
+	}(time.Now())
+
+	conn, err := dialTCPConn(ctx, cfg, m)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConf, err := serverNameTLSConfig(clientTLSConfig(cfg), m, serverName)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	tlsConf = transports.ClientTLSConfig(tlsConf)
+	// only the addresses given by the caller carry a certhash, resolvers
+	// drop the pins of the addresses they discover
+	if hashes := qnet.CertHashes(m); len(hashes) > 0 {
+		tlsConf.InsecureSkipVerify = true
+		tlsConf.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			certs := make([]*x509.Certificate, 0, len(rawCerts))
+			for _, raw := range rawCerts {
+				cert, err := x509.ParseCertificate(raw)
+				if err != nil {
+					return err
+				}
+				certs = append(certs, cert)
+			}
+
+			return verifyCertHash(certs, hashes)
+		}
+	}
+
+	tconn := tls.Client(conn, tlsConf)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- tconn.Handshake()
+	}()
+
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		conn.Close()
+		<-errc
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// like dialQuic, only established connections are metered, and their
+	// bytes are counted above TLS
+	secure := qnet.MeterConn(tconn, cfg.Metrics, labels)
+	return &transports.TLSConn{Conn: secure, State: tconn.ConnectionState()}, nil
+}
+
+// dialRace dials the udp multiaddr m over QUIC and, once QUIC had a head
+// start of cfg.HappyEyeballsDelay or failed, over TCP+TLS to the same host
+// and port. If tcpFirst is set, TCP+TLS gets the head start instead. The
+// first attempt to complete its handshake wins and the other one is
+// canceled. A delay which is not positive is replaced by
+// DefaultHappyEyeballsDelay. QUIC losing the race after its head start is
+// recorded in cfg.QuicCache as blocked.
+func dialRace(ctx context.Context, cfg *options.ClientConfig, m ma.Multiaddr, serverName string, tcpFirst bool) (net.Conn, error) {
+	mtcp, err := qnet.WithTransport(m, ma.P_TCP)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		conn net.Conn
+		err  error
+		quic bool
+	}
+
+	results := make(chan result, 2)
+	dialQuicRace := func() {
+		conn, err := dialQuic(ctx, cfg, m, serverName)
+		results <- result{conn, err, true}
+	}
+
+	dialTLSRace := func() {
+		conn, err := dialTLS(ctx, cfg, mtcp, serverName)
+		results <- result{conn, err, false}
+	}
+
+	first, second := dialQuicRace, dialTLSRace
+	if tcpFirst {
+		first, second = second, first
+	}
+
+	go first()
+	pending := 1
+
+	delay := cfg.HappyEyeballsDelay
+	if delay <= 0 {
+		delay = options.DefaultHappyEyeballsDelay
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	headStart := timer.C
+	startSecond := func() {
+		headStart = nil
+		pending++
+		go second()
+	}
+
+	var firstErr error
+	for pending > 0 {
+		select {
+		case <-headStart:
+			startSecond()
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				// QUIC is still pending although it had its head start
+				if !res.quic && pending > 0 && !tcpFirst {
+					storeQuicWorks(cfg, m, false)
+				}
+
+				// close the loser if it completes anyway
+				go func(pending int) {
+					for ; pending > 0; pending-- {
+						if res := <-results; res.err == nil {
+							res.conn.Close()
+						}
+					}
+				}(pending)
+
+				return res.conn, nil
+			}
+
+			if firstErr == nil {
+				firstErr = res.err
+			}
+
+			// the first attempt failed before its head start was over
+			if headStart != nil {
+				startSecond()
+			}
+		}
+	}
+
+	return nil, firstErr
+}
+
+// dialLabels returns the metrics labels of the dials of m over protocol.
+func dialLabels(m ma.Multiaddr, protocol string) quicmetrics.Labels {
+	return quicmetrics.Labels{
+		Role:     quicmetrics.RoleDialer,
+		Addr:     m.String(),
+		Protocol: protocol,
+	}
+}
+
+// reportDial reports to cfg.Metrics the outcome of a dial labelled l, which
+// started at start.
+func reportDial(ctx context.Context, cfg *options.ClientConfig, l quicmetrics.Labels, start time.Time, err error) {
+	switch {
+	case cfg.Metrics == nil:
+	case err == nil:
+		cfg.Metrics.Handshake(l, time.Since(start))
+	case errors.Is(ctx.Err(), context.Canceled):
+		// an attempt canceled by the caller, such as the loser of a
+		// race, tells nothing about the transport
+	default:
+		cfg.Metrics.DialFailed(l, qnet.ErrorType(err))
+	}
+}
+
+// quicTLSConfig returns the TLS config used to dial m over QUIC. QUIC always
+// requires TLS, so in insecure mode the certificate of the server is not
+// verified. Neither is it when m pins the certificate, see dialQuic.
+func quicTLSConfig(cfg *options.ClientConfig, m ma.Multiaddr, serverName string) (*tls.Config, error) {
+	base := clientTLSConfig(cfg)
+	tlsConf, err := serverNameTLSConfig(base, m, serverName)
+	if err != nil || (!cfg.Insecure && len(qnet.CertHashes(m)) == 0) {
+		return tlsConf, err
+	}
+
+	if tlsConf == base {
+		tlsConf = tlsConf.Clone()
+	}
+
+	tlsConf.InsecureSkipVerify = true
+	return tlsConf, nil
+}
+
+// clientTLSConfig returns the TLS config of a new connection, taken from the
+// TLS source if any.
+func clientTLSConfig(cfg *options.ClientConfig) *tls.Config {
+	if cfg.TLSSource != nil {
+		return cfg.TLSSource.ClientTLSConfig()
+	}
+
+	return cfg.TLSConf
+}
+
+// serverNameTLSConfig sets serverName, or the hostname of m if it is empty,
+// as the server name of tlsConf, since QUIC sessions are dialed on the
+// resolved address.
+func serverNameTLSConfig(tlsConf *tls.Config, m ma.Multiaddr, serverName string) (*tls.Config, error) {
+	if tlsConf != nil && tlsConf.ServerName != "" {
+		return tlsConf, nil
+	}
+
+	host := serverName
+	if host == "" {
+		var err error
+		if host, err = qnet.Hostname(m); err != nil {
+			return nil, err
+		}
+	}
+
+	if tlsConf == nil {
+		tlsConf = &tls.Config{}
+	} else {
+		tlsConf = tlsConf.Clone()
+	}
+
+	tlsConf.ServerName = host
+	return tlsConf, nil
+}
+
+// quicBlocked reports whether cfg.QuicCache remembers QUIC being blocked for
+// the udp multiaddr m.
+func quicBlocked(cfg *options.ClientConfig, m ma.Multiaddr) bool {
+	if cfg.QuicCache == nil {
+		return false
+	}
+
+	network, addr, err := quicCacheKey(m)
+	if err != nil {
+		return false
+	}
+
+	works, ok := cfg.QuicCache.Lookup(network, addr)
+	return ok && !works
+}
+
+// storeQuicWorks records in cfg.QuicCache whether dialing m over QUIC worked.
+func storeQuicWorks(cfg *options.ClientConfig, m ma.Multiaddr, works bool) {
+	if cfg.QuicCache == nil {
+		return
+	}
+
+	network, addr, err := quicCacheKey(m)
+	if err != nil {
+		return
+	}
+
+	cfg.QuicCache.Store(network, addr, works)
+}
+
+// quicCacheKey returns the network and the unresolved host:port of m, so
+// every address a hostname resolves to shares the same entry.
+func quicCacheKey(m ma.Multiaddr) (network, addr string, err error) {
+	network, err = qnet.Network(m)
+	if err != nil {
+		return
+	}
+
+	addr, _, err = qnet.ParseMultiaddr(m)
+	return
+}
+
+// verifyCertHash checks the leaf certificate of a peer against the pinned
+// hashes.
+func verifyCertHash(certs []*x509.Certificate, hashes []mh.Multihash) error {
+	if len(certs) == 0 {
+		return fmt.Errorf("no peer certificate to verify against certhash")
+	}
+
+	return qnet.VerifyCertHash(certs[0], hashes)
+}
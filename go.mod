@@ -1,7 +1,7 @@
 module github.com/gfanton/grpc-quic
 
 require (
-	github.com/bifurcation/mint v0.0.0-20180224032100-f55416195347 // indirect
+	github.com/bifurcation/mint v0.0.0-20180715133206-93c51c6ce115 // indirect
 	github.com/cheekybits/genny v1.0.0 // indirect
 	github.com/golang/protobuf v1.2.0
 	github.com/gopherjs/gopherjs v0.0.0-20181103185306-d547d1d9531e // indirect
@@ -2,138 +2,249 @@ package grpcquic
 
 import (
 	"context"
-	"crypto/tls"
-	"fmt"
+	"errors"
 	"net"
+	"reflect"
+	"strings"
 	"time"
+	"unsafe"
 
+	quicaltsvc "github.com/gfanton/grpc-quic/altsvc"
+	quicbalancer "github.com/gfanton/grpc-quic/balancer"
 	qnet "github.com/gfanton/grpc-quic/net"
 	options "github.com/gfanton/grpc-quic/opts"
+	quicresolver "github.com/gfanton/grpc-quic/resolver"
 	"github.com/gfanton/grpc-quic/transports"
-	quic "github.com/lucas-clemente/quic-go"
 	ma "github.com/multiformats/go-multiaddr"
+	madns "github.com/multiformats/go-multiaddr-dns"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/grpclog"
 )
 
-var quicConfig = &quic.Config{
-	// MaxReceiveStreamFlowControlWindow:     3 * (1 << 20),   // 3 MB
-	// MaxReceiveConnectionFlowControlWindow: 4.5 * (1 << 20), // 4.5 MB
-	// Versions: []quic.VersionNumber{101},
-	// AcceptCookie: func(clientAddr net.Addr, cookie *quic.Cookie) bool {
-	// 	// TODO(#6): require source address validation when under load
-	// 	return true
-	// },
-	KeepAlive: true,
-}
+// ErrStreamInterceptor is returned by Dial when a stream interceptor is set
+// with grpc.WithStreamInterceptor, which would replace the one marking the
+// streams for the latency balancer. Use opts.WithStreamInterceptor instead.
+var ErrStreamInterceptor = errors.New("grpc.WithStreamInterceptor replaces the interceptor of the balancer, use opts.WithStreamInterceptor")
 
-func newPacketConn(addr string) (net.PacketConn, error) {
+func newPacketConn(network, addr string) (net.PacketConn, error) {
 	// create a packet conn for outgoing connections
-	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	udpAddr, err := net.ResolveUDPAddr(network, addr)
 	if err != nil {
 		return nil, err
 	}
 
-	return net.ListenUDP("udp", udpAddr)
+	return net.ListenUDP(network, udpAddr)
 }
 
-func newQuicDialer(tlsConf *tls.Config) func(string, time.Duration) (net.Conn, error) {
+// newQuicDialer returns the dialer of the multiaddrs of a ClientConn. The
+// certificates of the servers at the addresses advertised through upgrade,
+// if not nil, are verified for the origin which advertised them, and those
+// resolved from a /dnsaddr domain, if not empty, for the domain, see
+// dialServerName.
+func newQuicDialer(cfg *options.ClientConfig, upgrade *quicaltsvc.Upgrade, domain string) func(string, time.Duration) (net.Conn, error) {
 	return func(target string, timeout time.Duration) (net.Conn, error) {
 		var err error
 
 		m, err := ma.NewMultiaddr(target)
 		if err != nil {
-			return nil, err
+			return nil, &qnet.MultiaddrError{Addr: target, Err: err}
 		}
 
-		laddr, protocol, err := qnet.ParseMultiaddr(m)
+		_, protocol, err := qnet.ParseMultiaddr(m)
 		if err != nil {
 			return nil, err
 		}
 
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		serverName := dialServerName(upgrade, domain, target, m)
+
 		if protocol == ma.P_UDP {
-			ctx, cancel := context.WithTimeout(context.Background(), timeout)
-			defer cancel()
+			tcpFirst := quicBlocked(cfg, m)
+			if cfg.HappyEyeballs {
+				return dialRace(ctx, cfg, m, serverName, tcpFirst)
+			}
+
+			if tcpFirst {
+				mtcp, err := qnet.WithTransport(m, ma.P_TCP)
+				if err != nil {
+					return nil, err
+				}
 
-			sess, err := quic.DialAddrContext(ctx, laddr, tlsConf, quicConfig)
-			if err != nil {
-				return nil, err
+				if conn, err := dialTLS(ctx, cfg, mtcp, serverName); err == nil {
+					return conn, nil
+				}
 			}
 
-			return qnet.NewConn(sess)
+			return dialQuic(ctx, cfg, m, serverName)
 		}
 
 		if protocol == ma.P_TCP {
-			return net.DialTimeout("tcp", laddr, timeout)
+			// pinned certificates are verified by the dialer, which also
+			// takes a new TLS config from the TLS source for every
+			// connection, and verifies servers for another name than the
+			// authority of the ClientConn
+			if len(qnet.CertHashes(m)) > 0 || ((cfg.TLSSource != nil || serverName != "") && !cfg.Insecure) {
+				return dialTLS(ctx, cfg, m, serverName)
+			}
+
+			return dialTCP(ctx, cfg, m)
 		}
 
-		return nil, fmt.Errorf("Invalid protocol")
+		return nil, &qnet.UnsupportedProtocolError{Addr: target, Protocol: ma.ProtocolWithCode(protocol).Name}
 	}
 }
 
-func Dial(target string, opts ...options.DialOption) (*grpc.ClientConn, error) {
-	cfg := options.NewClientConfig()
-	if err := cfg.Apply(opts...); err != nil {
-		return nil, err
+// dialServerName returns the name the certificate of the server at target
+// is verified for: the origin which advertised target through upgrade if
+// any, or the /dnsaddr domain target was resolved from, unless target has a
+// hostname of its own. It is empty if the host of target is used.
+func dialServerName(upgrade *quicaltsvc.Upgrade, domain string, target string, m ma.Multiaddr) string {
+	if upgrade != nil {
+		if name := upgrade.ServerName(target); name != "" {
+			return name
+		}
 	}
 
-	creds := transports.NewCredentials(cfg.TLSConf)
-	dialer := newQuicDialer(cfg.TLSConf)
-	grpcOpts := []grpc.DialOption{
-		grpc.WithDialer(dialer),
-		grpc.WithTransportCredentials(creds),
+	if qnet.HasHostname(m) {
+		return ""
 	}
 
-	grpcOpts = append(grpcOpts, cfg.GrpcDialOptions...)
-	return grpc.Dial(target, grpcOpts...)
+	return domain
 }
 
-func newListener(laddr string, tlsConf *tls.Config) (net.Listener, error) {
-	m, err := ma.NewMultiaddr(laddr)
-	if err != nil {
-		return nil, err
+// streamInterceptor marks streaming RPCs for the latency balancer before
+// handing them to next, if any.
+func streamInterceptor(next grpc.StreamClientInterceptor) grpc.StreamClientInterceptor {
+	if next == nil {
+		return quicbalancer.StreamClientInterceptor
 	}
 
-	laddr, protocol, err := qnet.ParseMultiaddr(m)
-	if err != nil {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return quicbalancer.StreamClientInterceptor(ctx, desc, cc, method, func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return next(ctx, desc, cc, method, streamer, opts...)
+		}, opts...)
+	}
+}
+
+// setsStreamInterceptor reports whether opt sets the stream interceptor of
+// the ClientConn. gRPC dial options are opaque, so opt is applied to scratch
+// gRPC options, whose interceptor is checked afterwards. Options which are
+// not functions of the gRPC options are assumed not to set it.
+func setsStreamInterceptor(opt grpc.DialOption) bool {
+	v := reflect.ValueOf(opt)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct || v.Elem().NumField() != 1 {
+		return false
+	}
+
+	apply := v.Elem().Field(0)
+	if apply.Kind() != reflect.Func || apply.Type().NumIn() != 1 || apply.Type().In(0).Kind() != reflect.Ptr {
+		return false
+	}
+
+	scratch := reflect.New(apply.Type().In(0).Elem())
+	interceptor := scratch.Elem().FieldByName("streamInt")
+	if !interceptor.IsValid() || interceptor.Type() != reflect.TypeOf(grpc.StreamClientInterceptor(nil)) {
+		return false
+	}
+
+	// the fields are unexported, they are only reachable through their
+	// address
+	apply = reflect.NewAt(apply.Type(), unsafe.Pointer(apply.UnsafeAddr())).Elem()
+	interceptor = reflect.NewAt(interceptor.Type(), unsafe.Pointer(interceptor.UnsafeAddr())).Elem()
+
+	marker := reflect.ValueOf(grpc.StreamClientInterceptor(quicbalancer.StreamClientInterceptor))
+	interceptor.Set(marker)
+	apply.Call([]reflect.Value{scratch})
+
+	return interceptor.IsNil() || interceptor.Pointer() != marker.Pointer()
+}
+
+// Dial creates a client connection to the multiaddr target configured by
+// opts. Stream interceptors must be given with opts.WithStreamInterceptor,
+// Dial fails with ErrStreamInterceptor if the gRPC options set one.
+func Dial(target string, opts ...options.DialOption) (*grpc.ClientConn, error) {
+	cfg := options.NewClientConfig()
+	if err := cfg.Apply(opts...); err != nil {
 		return nil, err
 	}
 
-	if protocol == ma.P_UDP {
-		pconn, err := newPacketConn(laddr)
+	for _, opt := range cfg.GrpcDialOptions {
+		if setsStreamInterceptor(opt) {
+			return nil, ErrStreamInterceptor
+		}
+	}
+
+	creds := transports.NewCredentials(cfg.TLSConf)
+	if cfg.Insecure {
+		grpclog.Warningf("grpcquic: insecure mode, TCP is in plaintext and QUIC certificates are not verified")
+		creds = transports.NewInsecureCredentials()
+	}
+
+	// /dnsaddr targets are resolved into a set of multiaddrs by the
+	// dnsaddr resolver
+	var (
+		release func()
+		domain  string
+	)
+	if strings.HasPrefix(target, "/dnsaddr/") {
+		m, err := ma.NewMultiaddr(target)
 		if err != nil {
 			return nil, err
 		}
 
-		ql, err := quic.Listen(pconn, tlsConf, quicConfig)
-		if err != nil {
+		// the resolved IP addresses are verified for the domain
+		if domain, err = m.ValueForProtocol(madns.DnsaddrProtocol.Code); err != nil {
 			return nil, err
 		}
 
-		return qnet.Listen(ql), nil
+		dnsaddr := quicresolver.DNSAddrTarget(m, &quicresolver.DNSAddrConfig{
+			Resolver:        cfg.DNSResolver,
+			RefreshInterval: cfg.DNSRefreshInterval,
+		})
+
+		target = dnsaddr
+		release = func() { quicresolver.ReleaseDNSAddrTarget(dnsaddr) }
 	}
 
-	if protocol == ma.P_TCP {
-		l, err := net.Listen("tcp", laddr)
-		if err != nil {
-			return nil, err
+	// the upgrade wraps the resolver of target to add the QUIC endpoints
+	// advertised by the servers
+	var upgrade *quicaltsvc.Upgrade
+	if cfg.QuicUpgrade {
+		upgrade = quicaltsvc.NewUpgrade(target)
+		target = upgrade.Target()
+
+		releaseInner := release
+		release = func() {
+			upgrade.Release()
+			if releaseInner != nil {
+				releaseInner()
+			}
 		}
-		return l, nil
 	}
 
-	return nil, fmt.Errorf("Invalid protocol `%s`", m)
-}
-
-func NewServer(laddr string, opts ...options.ServerOption) (*grpc.Server, net.Listener, error) {
-	cfg := options.NewServerConfig()
-	if err := cfg.Apply(opts...); err != nil {
-		return nil, nil, err
+	dialer := newQuicDialer(cfg, upgrade, domain)
+	grpcOpts := []grpc.DialOption{
+		grpc.WithDialer(dialer),
+		grpc.WithTransportCredentials(creds),
+		grpc.WithStreamInterceptor(streamInterceptor(cfg.StreamInterceptor)),
 	}
 
-	creds := transports.NewCredentials(cfg.TLSConf)
-	l, err := newListener(laddr, cfg.TLSConf)
+	grpcOpts = append(grpcOpts, cfg.GrpcDialOptions...)
+
+	cc, err := grpc.Dial(target, grpcOpts...)
 	if err != nil {
-		return nil, nil, err
+		if release != nil {
+			release()
+		}
+
+		return nil, err
+	}
+
+	if upgrade != nil {
+		go upgrade.Discover(cc)
 	}
 
-	return grpc.NewServer(grpc.Creds(creds)), l, err
+	return cc, nil
 }
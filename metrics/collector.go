@@ -0,0 +1,297 @@
+package quicmetrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultHandshakeBuckets are the upper bounds, in seconds, of the
+// handshake latency histograms.
+var DefaultHandshakeBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// DefaultLifetimeBuckets are the upper bounds, in seconds, of the session
+// lifetime histograms.
+var DefaultLifetimeBuckets = []float64{1, 10, 60, 300, 900, 3600, 4 * 3600, 24 * 3600}
+
+var _ Metrics = (*Collector)(nil)
+
+// Collector is a Metrics keeping its measures in memory, written in the
+// Prometheus text format by WriteTo and served by ServeHTTP.
+type Collector struct {
+	// the bytes are counted for every read and write, without taking mu
+	bytesRead    counters
+	bytesWritten counters
+
+	mu             sync.Mutex
+	handshakes     map[Labels]*histogram
+	active         map[Labels]int64
+	lifetimes      map[Labels]*histogram
+	acceptFailures map[failure]uint64
+	dialFailures   map[failure]uint64
+}
+
+// counters are per-label counters updated atomically, the lock of the map
+// is only taken to add a label.
+type counters struct {
+	m sync.Map // Labels to *uint64
+}
+
+func (c *counters) add(l Labels, n uint64) {
+	v, ok := c.m.Load(l)
+	if !ok {
+		v, _ = c.m.LoadOrStore(l, new(uint64))
+	}
+
+	atomic.AddUint64(v.(*uint64), n)
+}
+
+func (c *counters) snapshot() map[Labels]uint64 {
+	values := make(map[Labels]uint64)
+	c.m.Range(func(l, v interface{}) bool {
+		values[l.(Labels)] = atomic.LoadUint64(v.(*uint64))
+		return true
+	})
+
+	return values
+}
+
+type failure struct {
+	Labels
+	errType string
+}
+
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, bound := range h.buckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+
+	h.sum += v
+	h.count++
+}
+
+// NewCollector returns an empty collector.
+func NewCollector() *Collector {
+	return &Collector{
+		handshakes:     make(map[Labels]*histogram),
+		active:         make(map[Labels]int64),
+		lifetimes:      make(map[Labels]*histogram),
+		acceptFailures: make(map[failure]uint64),
+		dialFailures:   make(map[failure]uint64),
+	}
+}
+
+func (c *Collector) BytesRead(l Labels, n int) {
+	c.bytesRead.add(l, uint64(n))
+}
+
+func (c *Collector) BytesWritten(l Labels, n int) {
+	c.bytesWritten.add(l, uint64(n))
+}
+
+func (c *Collector) Handshake(l Labels, d time.Duration) {
+	c.mu.Lock()
+	observe(c.handshakes, l, DefaultHandshakeBuckets, d)
+	c.mu.Unlock()
+}
+
+func (c *Collector) SessionOpened(l Labels) {
+	c.mu.Lock()
+	c.active[l]++
+	c.mu.Unlock()
+}
+
+func (c *Collector) SessionClosed(l Labels, lifetime time.Duration) {
+	c.mu.Lock()
+	c.active[l]--
+	observe(c.lifetimes, l, DefaultLifetimeBuckets, lifetime)
+	c.mu.Unlock()
+}
+
+func (c *Collector) AcceptFailed(l Labels, errType string) {
+	c.mu.Lock()
+	c.acceptFailures[failure{l, errType}]++
+	c.mu.Unlock()
+}
+
+func (c *Collector) DialFailed(l Labels, errType string) {
+	c.mu.Lock()
+	c.dialFailures[failure{l, errType}]++
+	c.mu.Unlock()
+}
+
+func observe(hs map[Labels]*histogram, l Labels, buckets []float64, d time.Duration) {
+	h, ok := hs[l]
+	if !ok {
+		h = newHistogram(buckets)
+		hs[l] = h
+	}
+
+	h.observe(d.Seconds())
+}
+
+// WriteTo writes the measures to w in the Prometheus text format.
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+
+	writeCounters(cw, "grpcquic_bytes_read_total", "Bytes read from connections.", c.bytesRead.snapshot())
+	writeCounters(cw, "grpcquic_bytes_written_total", "Bytes written to connections.", c.bytesWritten.snapshot())
+
+	c.mu.Lock()
+	writeHistograms(cw, "grpcquic_handshake_seconds", "Time taken to establish connections.", c.handshakes)
+
+	cw.header("grpcquic_active_sessions", "Sessions currently open.", "gauge")
+	for _, l := range sortedLabels(c.active) {
+		cw.sample("grpcquic_active_sessions", formatLabels(l), float64(c.active[l]))
+	}
+
+	writeHistograms(cw, "grpcquic_session_lifetime_seconds", "Lifetime of closed sessions.", c.lifetimes)
+	writeFailures(cw, "grpcquic_accept_failures_total", "Sessions dropped by listeners, by error type.", c.acceptFailures)
+	writeFailures(cw, "grpcquic_dial_failures_total", "Failed dials, by error type.", c.dialFailures)
+	c.mu.Unlock()
+
+	if cw.err == nil {
+		cw.err = cw.w.(*bufio.Writer).Flush()
+	}
+
+	return cw.n, cw.err
+}
+
+// ServeHTTP serves the measures in the Prometheus text format.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.WriteTo(w)
+}
+
+func writeCounters(cw *countingWriter, name, help string, counters map[Labels]uint64) {
+	cw.header(name, help, "counter")
+	for _, l := range sortedLabels(counters) {
+		cw.sample(name, formatLabels(l), float64(counters[l]))
+	}
+}
+
+func writeHistograms(cw *countingWriter, name, help string, hs map[Labels]*histogram) {
+	cw.header(name, help, "histogram")
+	for _, l := range sortedLabels(hs) {
+		h, labels := hs[l], formatLabels(l)
+		for i, bound := range h.buckets {
+			le := `le="` + strconv.FormatFloat(bound, 'g', -1, 64) + `"`
+			cw.sample(name+"_bucket", labels+","+le, float64(h.counts[i]))
+		}
+
+		cw.sample(name+"_bucket", labels+`,le="+Inf"`, float64(h.count))
+		cw.sample(name+"_sum", labels, h.sum)
+		cw.sample(name+"_count", labels, float64(h.count))
+	}
+}
+
+func writeFailures(cw *countingWriter, name, help string, failures map[failure]uint64) {
+	keys := make([]failure, 0, len(failures))
+	for f := range failures {
+		keys = append(keys, f)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Labels != keys[j].Labels {
+			return lessLabels(keys[i].Labels, keys[j].Labels)
+		}
+
+		return keys[i].errType < keys[j].errType
+	})
+
+	cw.header(name, help, "counter")
+	for _, f := range keys {
+		cw.sample(name, formatLabels(f.Labels)+`,error="`+escape(f.errType)+`"`, float64(failures[f]))
+	}
+}
+
+// sortedLabels returns the keys of m, which must be a map keyed by Labels,
+// in a stable order.
+func sortedLabels(m interface{}) []Labels {
+	var keys []Labels
+	switch m := m.(type) {
+	case map[Labels]uint64:
+		for l := range m {
+			keys = append(keys, l)
+		}
+	case map[Labels]int64:
+		for l := range m {
+			keys = append(keys, l)
+		}
+	case map[Labels]*histogram:
+		for l := range m {
+			keys = append(keys, l)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return lessLabels(keys[i], keys[j]) })
+	return keys
+}
+
+func lessLabels(a, b Labels) bool {
+	if a.Role != b.Role {
+		return a.Role < b.Role
+	}
+
+	if a.Addr != b.Addr {
+		return a.Addr < b.Addr
+	}
+
+	return a.Protocol < b.Protocol
+}
+
+func formatLabels(l Labels) string {
+	return fmt.Sprintf(`role="%s",addr="%s",protocol="%s"`, escape(l.Role), escape(l.Addr), escape(l.Protocol))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+// countingWriter writes the lines of the text format, keeping the first
+// error and the number of bytes written.
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) printf(format string, args ...interface{}) {
+	if cw.err != nil {
+		return
+	}
+
+	n, err := fmt.Fprintf(cw.w, format, args...)
+	cw.n += int64(n)
+	cw.err = err
+}
+
+func (cw *countingWriter) header(name, help, typ string) {
+	cw.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func (cw *countingWriter) sample(name, labels string, v float64) {
+	cw.printf("%s{%s} %s\n", name, labels, strconv.FormatFloat(v, 'g', -1, 64))
+}
@@ -0,0 +1,58 @@
+// Package quicmetrics measures the connections of gRPC clients and servers,
+// over QUIC and TCP alike, so both transports can be compared. Metrics is
+// the interface the dialers and listeners report to, and Collector a
+// default implementation exposing them in the Prometheus text format.
+package quicmetrics
+
+import "time"
+
+// Roles of the connections.
+const (
+	RoleListener = "listener"
+	RoleDialer   = "dialer"
+)
+
+// Protocols of the connections.
+const (
+	ProtocolQUIC = "udp/quic"
+	ProtocolTCP  = "tcp"
+)
+
+// Labels identify the listener or the dial target a measure belongs to.
+type Labels struct {
+	// Role is either RoleListener or RoleDialer.
+	Role string
+
+	// Addr is the address of a listener, or the target of a dialer.
+	Addr string
+
+	// Protocol is either ProtocolQUIC or ProtocolTCP.
+	Protocol string
+}
+
+// Metrics receives the measures of connections. It must be safe for
+// concurrent use.
+type Metrics interface {
+	// BytesRead and BytesWritten count the bytes read from and written to
+	// connections, as seen by gRPC: the payload of the streams of QUIC
+	// connections, and the bytes above TLS of TCP connections, so the
+	// handshakes and the framing of neither transport are counted.
+	BytesRead(l Labels, n int)
+	BytesWritten(l Labels, n int)
+
+	// Handshake observes the time taken to establish a connection. Dialers
+	// observe their whole dial, listeners the TLS handshake of their TCP
+	// connections and the time from the first packet of a QUIC client to
+	// the acceptance of its session.
+	Handshake(l Labels, d time.Duration)
+
+	// SessionOpened and SessionClosed track the active sessions, along
+	// with their lifetime.
+	SessionOpened(l Labels)
+	SessionClosed(l Labels, lifetime time.Duration)
+
+	// AcceptFailed and DialFailed count the failures by error type, see
+	// net.ErrorType.
+	AcceptFailed(l Labels, errType string)
+	DialFailed(l Labels, errType string)
+}
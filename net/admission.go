@@ -0,0 +1,135 @@
+package net
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	quic "github.com/lucas-clemente/quic-go"
+)
+
+// AdmissionFunc decides whether a new session is handed to the server, from
+// its remote address and the TLS state of its handshake. It is called before
+// the session is wrapped into a connection, a non nil error rejects it.
+type AdmissionFunc func(remote net.Addr, state tls.ConnectionState) error
+
+// RejectError rejects a session with an application error code, which is
+// sent to the peer along with the reason.
+type RejectError struct {
+	Code   quic.ErrorCode
+	Reason string
+}
+
+// Reject returns an error rejecting a session with code.
+func Reject(code quic.ErrorCode, reason string) error {
+	return &RejectError{Code: code, Reason: reason}
+}
+
+func (e *RejectError) Error() string {
+	return fmt.Sprintf("session rejected (code %#x): %s", uint64(e.Code), e.Reason)
+}
+
+// admit runs f on sess and closes sess if it is rejected.
+func admit(f AdmissionFunc, sess quic.Session) error {
+	if f == nil {
+		return nil
+	}
+
+	err := f(sess.RemoteAddr(), ConnectionState(sess))
+	if err == nil {
+		return nil
+	}
+
+	code := AdmissionRejectedCode
+	var rerr *RejectError
+	if errors.As(err, &rerr) {
+		code = rerr.Code
+	}
+
+	sess.CloseWithError(code, err)
+	return err
+}
+
+// verifiedSession is a session whose client certificate was verified by
+// authenticate, its TLS state carries the verified chains.
+type verifiedSession struct {
+	quic.Session
+	chains [][]*x509.Certificate
+}
+
+// GetVersion returns the QUIC version of the session, see Version.
+func (s *verifiedSession) GetVersion() quic.VersionNumber {
+	v, _ := Version(s.Session)
+	return v
+}
+
+// authenticate enforces the client authentication of conf on sess, as a TLS
+// handshake would, and closes sess with AuthFailureCode if it fails. The
+// returned session carries the verified chains of the client certificate.
+func authenticate(conf *tls.Config, sess quic.Session) (quic.Session, error) {
+	if conf == nil || conf.ClientAuth == tls.NoClientCert {
+		return sess, nil
+	}
+
+	chains, err := verifyClientCert(conf, sess.ConnectionState().PeerCertificates)
+	if err != nil {
+		rerr := &RejectError{Code: AuthFailureCode, Reason: err.Error()}
+		sess.CloseWithError(rerr.Code, rerr)
+		return sess, rerr
+	}
+
+	if chains == nil {
+		return sess, nil
+	}
+
+	return &verifiedSession{Session: sess, chains: chains}, nil
+}
+
+// verifyClientCert verifies certs, given by a client, as the server side of
+// a TLS handshake configured by conf does.
+func verifyClientCert(conf *tls.Config, certs []*x509.Certificate) ([][]*x509.Certificate, error) {
+	if len(certs) == 0 && (conf.ClientAuth == tls.RequireAnyClientCert || conf.ClientAuth == tls.RequireAndVerifyClientCert) {
+		return nil, errors.New("client did not provide a certificate")
+	}
+
+	var chains [][]*x509.Certificate
+	if len(certs) > 0 && (conf.ClientAuth == tls.VerifyClientCertIfGiven || conf.ClientAuth == tls.RequireAndVerifyClientCert) {
+		now := time.Now()
+		if conf.Time != nil {
+			now = conf.Time()
+		}
+
+		opts := x509.VerifyOptions{
+			Roots:         conf.ClientCAs,
+			CurrentTime:   now,
+			Intermediates: x509.NewCertPool(),
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}
+
+		for _, cert := range certs[1:] {
+			opts.Intermediates.AddCert(cert)
+		}
+
+		var err error
+		chains, err = certs[0].Verify(opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to verify client certificate: %w", err)
+		}
+	}
+
+	if conf.VerifyPeerCertificate != nil {
+		rawCerts := make([][]byte, len(certs))
+		for i, cert := range certs {
+			rawCerts[i] = cert.Raw
+		}
+
+		if err := conf.VerifyPeerCertificate(rawCerts, chains); err != nil {
+			return nil, err
+		}
+	}
+
+	return chains, nil
+}
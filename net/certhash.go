@@ -0,0 +1,170 @@
+package net
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+
+	ma "github.com/multiformats/go-multiaddr"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// CertHashProtocol is the /certhash protocol, which pins the certificate of
+// the server. Its value is a multihash of either the DER certificate or its
+// DER public key, encoded in multibase: base64url ("u" prefix) or base58btc
+// ("z" prefix). The hash is SHA-256 or stronger, see checkCertHash.
+var CertHashProtocol = ma.Protocol{
+	Code:       0x01d2,
+	Size:       ma.LengthPrefixedVarSize,
+	Name:       "certhash",
+	VCode:      ma.CodeToVarint(0x01d2),
+	Transcoder: ma.NewTranscoderFromFunctions(certHashStB, certHashBtS, nil),
+}
+
+func init() {
+	if err := ma.AddProtocol(CertHashProtocol); err != nil {
+		panic(fmt.Errorf("error registering certhash protocol: %s", err))
+	}
+}
+
+func certHashStB(s string) ([]byte, error) {
+	if len(s) < 2 {
+		return nil, fmt.Errorf("invalid certhash `%s`", s)
+	}
+
+	var (
+		b   []byte
+		err error
+	)
+
+	switch s[0] {
+	case 'u':
+		b, err = base64.RawURLEncoding.DecodeString(s[1:])
+	case 'z':
+		var m mh.Multihash
+		m, err = mh.FromB58String(s[1:])
+		b = m
+	default:
+		return nil, fmt.Errorf("unsupported multibase encoding of certhash `%s`", s)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid certhash `%s`: %v", s, err)
+	}
+
+	if err := checkCertHash(b); err != nil {
+		return nil, fmt.Errorf("invalid certhash `%s`: %v", s, err)
+	}
+
+	return b, nil
+}
+
+func certHashBtS(b []byte) (string, error) {
+	if err := checkCertHash(b); err != nil {
+		return "", err
+	}
+
+	return "u" + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// certHashFunctions are the hash functions /certhash accepts, SHA-256 or
+// stronger.
+var certHashFunctions = map[uint64]bool{
+	mh.SHA2_256: true,
+	mh.SHA2_512: true,
+	mh.SHA3_256: true,
+	mh.SHA3_384: true,
+	mh.SHA3_512: true,
+}
+
+// checkCertHash returns an error if the multihash h is not a full digest of
+// one of certHashFunctions, truncated digests are too weak to pin a
+// certificate.
+func checkCertHash(h []byte) error {
+	dh, err := mh.Decode(h)
+	if err != nil {
+		return err
+	}
+
+	if !certHashFunctions[dh.Code] {
+		return fmt.Errorf("unsupported hash function `%s`, must be SHA-256 or stronger", dh.Name)
+	}
+
+	if dh.Length != mh.DefaultLengths[dh.Code] {
+		return fmt.Errorf("truncated %s digest of %d bytes", dh.Name, dh.Length)
+	}
+
+	return nil
+}
+
+// CertHashes returns the certificate hashes pinned by m.
+func CertHashes(m ma.Multiaddr) []mh.Multihash {
+	var hashes []mh.Multihash
+	ma.ForEach(m, func(c ma.Component) bool {
+		if c.Protocol().Code == CertHashProtocol.Code {
+			hashes = append(hashes, mh.Multihash(c.RawValue()))
+		}
+		return true
+	})
+
+	return hashes
+}
+
+// VerifyCertHash checks that the certificate or the public key of cert
+// matches one of hashes. Hashes weaker than SHA-256 or truncated never
+// match.
+func VerifyCertHash(cert *x509.Certificate, hashes []mh.Multihash) error {
+	for _, h := range hashes {
+		if checkCertHash(h) != nil {
+			continue
+		}
+
+		dh, err := mh.Decode(h)
+		if err != nil {
+			continue
+		}
+
+		for _, data := range [][]byte{cert.Raw, cert.RawSubjectPublicKeyInfo} {
+			sum, err := mh.Sum(data, dh.Code, dh.Length)
+			if err == nil && bytes.Equal(sum, h) {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("certificate of `%s` does not match any certhash", cert.Subject)
+}
+
+// WithoutCertHash returns m without its /certhash components, for multiaddrs
+// read from an unauthenticated source such as DNS, whose pins cannot be
+// trusted.
+func WithoutCertHash(m ma.Multiaddr) ma.Multiaddr {
+	if len(CertHashes(m)) == 0 {
+		return m
+	}
+
+	var parts []ma.Multiaddr
+	for _, part := range ma.Split(m) {
+		if part.Protocols()[0].Code != CertHashProtocol.Code {
+			parts = append(parts, part)
+		}
+	}
+
+	return ma.Join(parts...)
+}
+
+// WithCertHash returns m pinned to the SHA-256 hash of cert.
+func WithCertHash(m ma.Multiaddr, cert *x509.Certificate) (ma.Multiaddr, error) {
+	sum, err := mh.Sum(cert.Raw, mh.SHA2_256, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := ma.NewComponent(CertHashProtocol.Name, "u"+base64.RawURLEncoding.EncodeToString(sum))
+	if err != nil {
+		return nil, err
+	}
+
+	return m.Encapsulate(c), nil
+}
@@ -0,0 +1,126 @@
+package net
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	quic "github.com/lucas-clemente/quic-go"
+)
+
+// Application error codes closing sessions, they are sent to the peer along
+// with a reason and reported there as a PeerResetError.
+const (
+	// AdmissionRejectedCode closes the sessions rejected by an admission
+	// hook, unless it returned a RejectError.
+	AdmissionRejectedCode = applicationCodeBase + iota
+
+	// ShutdownCode closes the sessions of a server shutting down.
+	ShutdownCode
+
+	// GoAwayCode closes the sessions of a server once their calls are
+	// done, new calls must be sent to another connection.
+	GoAwayCode
+
+	// IdleCode closes the sessions which did not open a stream within the
+	// accept timeout of their listener.
+	IdleCode
+
+	// AuthFailureCode closes sessions whose peer could not be
+	// authenticated, or is not allowed to call the server.
+	AuthFailureCode
+
+	// AdminCloseCode closes sessions on behalf of an operator, through
+	// Registry.Close.
+	AdminCloseCode
+)
+
+var closeCodeNames = map[quic.ErrorCode]string{
+	AdmissionRejectedCode: "admission rejected",
+	ShutdownCode:          "server shutdown",
+	GoAwayCode:            "go away",
+	IdleCode:              "idle",
+	AuthFailureCode:       "authentication failure",
+	AdminCloseCode:        "closed by an operator",
+}
+
+// CloseCode returns the application error code a peer closed its session
+// with, ok is false if err is not a PeerResetError.
+func CloseCode(err error) (code quic.ErrorCode, ok bool) {
+	var perr *PeerResetError
+	if !errors.As(err, &perr) {
+		return 0, false
+	}
+
+	return perr.Code, true
+}
+
+// DefaultDrainTimeout bounds the time Close waits for the data queued on a
+// connection to reach the peer before closing its session.
+const DefaultDrainTimeout = time.Second
+
+// Close closes the connection gracefully: the data queued on its stream is
+// sent, and the session is closed once the peer closed its side of the
+// stream, or after DefaultDrainTimeout.
+// Any blocked Read or Write operations will be unblocked and return errors.
+func (c *Conn) Close() error {
+	return c.closeSession(true, func() error {
+		return c.sess.Close()
+	})
+}
+
+// CloseWithError closes the connection gracefully like Close, and sends the
+// application error code and reason to the peer.
+func (c *Conn) CloseWithError(code quic.ErrorCode, reason string) error {
+	return c.closeSession(true, func() error {
+		return c.sess.CloseWithError(code, errors.New(reason))
+	})
+}
+
+// abort closes the session at once, dropping any data not yet sent.
+func (c *Conn) abort(code quic.ErrorCode, reason string) error {
+	return c.closeSession(false, func() error {
+		return c.sess.CloseWithError(code, errors.New(reason))
+	})
+}
+
+func (c *Conn) closeSession(drain bool, closeFn func() error) error {
+	err := errConnClosed
+	c.closeOnce.Do(func() {
+		c.stream.Close()
+		if drain {
+			c.drain()
+		}
+
+		err = closeFn()
+	})
+
+	return err
+}
+
+var errConnClosed = errors.New("connection already closed")
+
+// drain waits for the peer to close its side of the stream, which it does
+// once it read everything sent on it, for the session to be closed or for
+// DefaultDrainTimeout.
+func (c *Conn) drain() {
+	timer := time.NewTimer(DefaultDrainTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-c.readDone:
+	case <-c.sess.Context().Done():
+	case <-timer.C:
+	}
+}
+
+// readFailed records that the peer closed its side of the stream, or that
+// the stream failed.
+func (c *Conn) readFailed(err error) {
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		// deadlines do not end the stream
+		return
+	}
+
+	c.readOnce.Do(func() { close(c.readDone) })
+}
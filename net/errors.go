@@ -0,0 +1,220 @@
+package net
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+
+	quic "github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/qerr"
+)
+
+// applicationCodeBase is the lowest application error code closing sessions,
+// above every gQUIC error code.
+const applicationCodeBase quic.ErrorCode = 0x100
+
+// The errors below are returned by the dialers and listeners of this module.
+// They implement Temporary, so gRPC only retries the dials that may succeed
+// later, and can be matched with errors.As.
+
+// MultiaddrError reports a malformed multiaddr, it is permanent.
+type MultiaddrError struct {
+	Addr string
+	Err  error
+}
+
+func (e *MultiaddrError) Error() string {
+	return fmt.Sprintf("invalid multiaddr `%s`: %v", e.Addr, e.Err)
+}
+
+func (e *MultiaddrError) Unwrap() error { return e.Err }
+
+// Temporary returns false.
+func (e *MultiaddrError) Temporary() bool { return false }
+
+// UnsupportedProtocolError reports a protocol of a multiaddr that cannot be
+// dialed or listened on, it is permanent.
+type UnsupportedProtocolError struct {
+	Addr     string
+	Protocol string
+}
+
+func (e *UnsupportedProtocolError) Error() string {
+	return fmt.Sprintf("not supported `%s` in `%s`", e.Protocol, e.Addr)
+}
+
+// Temporary returns false.
+func (e *UnsupportedProtocolError) Temporary() bool { return false }
+
+// HandshakeTimeoutError reports a QUIC handshake which did not complete in
+// time, it is temporary.
+type HandshakeTimeoutError struct {
+	Addr string
+	Err  error
+}
+
+func (e *HandshakeTimeoutError) Error() string {
+	return fmt.Sprintf("handshake with %s timed out: %v", e.Addr, e.Err)
+}
+
+func (e *HandshakeTimeoutError) Unwrap() error { return e.Err }
+
+// Temporary returns true.
+func (e *HandshakeTimeoutError) Temporary() bool { return true }
+
+// Timeout returns true.
+func (e *HandshakeTimeoutError) Timeout() bool { return true }
+
+// VersionMismatchError reports a peer without any QUIC version in common,
+// it is permanent.
+type VersionMismatchError struct {
+	Addr string
+	Err  error
+}
+
+func (e *VersionMismatchError) Error() string {
+	return fmt.Sprintf("no QUIC version in common with %s: %v", e.Addr, e.Err)
+}
+
+func (e *VersionMismatchError) Unwrap() error { return e.Err }
+
+// Temporary returns false.
+func (e *VersionMismatchError) Temporary() bool { return false }
+
+// IdleTimeoutError reports a QUIC session closed after staying idle too
+// long, it is temporary.
+type IdleTimeoutError struct {
+	Addr string
+	Err  error
+}
+
+func (e *IdleTimeoutError) Error() string {
+	return fmt.Sprintf("session with %s idle for too long: %v", e.Addr, e.Err)
+}
+
+func (e *IdleTimeoutError) Unwrap() error { return e.Err }
+
+// Temporary returns true.
+func (e *IdleTimeoutError) Temporary() bool { return true }
+
+// Timeout returns true.
+func (e *IdleTimeoutError) Timeout() bool { return true }
+
+// PeerResetError reports a QUIC session closed or reset by the peer, with
+// the error code and reason it gave. It is temporary unless the peer
+// rejected the session.
+type PeerResetError struct {
+	Addr   string
+	Code   quic.ErrorCode
+	Reason string
+	Err    error
+}
+
+func (e *PeerResetError) Error() string {
+	if name, ok := closeCodeNames[e.Code]; ok {
+		return fmt.Sprintf("session closed by %s (%s): %s", e.Addr, name, e.Reason)
+	}
+
+	return fmt.Sprintf("session reset by %s (code %#x): %s", e.Addr, uint64(e.Code), e.Reason)
+}
+
+func (e *PeerResetError) Unwrap() error { return e.Err }
+
+// Temporary returns true if the peer reset the session, shut down, went away
+// or closed an idle session, and false if it rejected the session, with
+// AdmissionRejectedCode, AuthFailureCode or an application code such as the
+// one of a RejectError.
+func (e *PeerResetError) Temporary() bool {
+	switch e.Code {
+	case ShutdownCode, GoAwayCode, IdleCode, AdminCloseCode:
+		return true
+	}
+
+	return e.Code < applicationCodeBase
+}
+
+// WrapError maps the QUIC errors of a session with addr to the errors above,
+// other errors are returned as is.
+func WrapError(addr string, err error) error {
+	var quicErr *qerr.QuicError
+	switch e := err.(type) {
+	case *qerr.QuicError:
+		quicErr = e
+	case qerr.ErrorCode:
+		quicErr = qerr.Error(e, "")
+	default:
+		return err
+	}
+
+	switch code := quicErr.ErrorCode; {
+	case code == qerr.HandshakeTimeout:
+		return &HandshakeTimeoutError{Addr: addr, Err: err}
+	case code == qerr.NetworkIdleTimeout || code == qerr.TimeoutsWithOpenStreams:
+		return &IdleTimeoutError{Addr: addr, Err: err}
+	case code == qerr.InvalidVersion || code == qerr.InvalidVersionNegotiationPacket ||
+		code == qerr.VersionNegotiationMismatch:
+		return &VersionMismatchError{Addr: addr, Err: err}
+	case code == qerr.PeerGoingAway || code == qerr.PublicReset || quic.ErrorCode(code) >= applicationCodeBase:
+		return &PeerResetError{
+			Addr:   addr,
+			Code:   quic.ErrorCode(code),
+			Reason: quicErr.ErrorMessage,
+			Err:    err,
+		}
+	}
+
+	return err
+}
+
+// ErrorType returns a short name for the kind of err, such as
+// "handshake_timeout" or "peer_reset", meant to label failure metrics.
+func ErrorType(err error) string {
+	var (
+		handshakeErr   *HandshakeTimeoutError
+		idleErr        *IdleTimeoutError
+		versionErr     *VersionMismatchError
+		resetErr       *PeerResetError
+		multiaddrErr   *MultiaddrError
+		protocolErr    *UnsupportedProtocolError
+		rejectErr      *RejectError
+		authorityErr   x509.UnknownAuthorityError
+		certificateErr x509.CertificateInvalidError
+		hostnameErr    x509.HostnameError
+		netErr         net.Error
+	)
+
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &handshakeErr):
+		return "handshake_timeout"
+	case errors.As(err, &idleErr):
+		return "idle_timeout"
+	case errors.As(err, &versionErr):
+		return "version_mismatch"
+	case errors.As(err, &resetErr):
+		return "peer_reset"
+	case errors.As(err, &multiaddrErr):
+		return "invalid_multiaddr"
+	case errors.As(err, &protocolErr):
+		return "unsupported_protocol"
+	case errors.As(err, &rejectErr):
+		return errTypeRejected
+	case err == ErrAcceptTimeout:
+		return "accept_timeout"
+	case errors.As(err, &authorityErr), errors.As(err, &certificateErr), errors.As(err, &hostnameErr):
+		return "certificate"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &netErr):
+		return "network"
+	}
+
+	return "other"
+}
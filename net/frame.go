@@ -0,0 +1,217 @@
+package net
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/http2/hpack"
+)
+
+// Frame types used to carry a single gRPC call on a QUIC stream. Every frame
+// starts with a 1 byte type followed by a 4 bytes big endian payload length.
+const (
+	frameData     byte = 0x0
+	frameHeaders  byte = 0x1
+	frameTrailers byte = 0x2
+)
+
+const (
+	frameHeaderLen = 5
+	maxFrameSize   = 1 << 24
+
+	// maxHeaderListSize bounds the decoded size of a header block, counted
+	// as in HTTP/2 SETTINGS_MAX_HEADER_LIST_SIZE, like the default of
+	// http2.Server.
+	maxHeaderListSize = 16 << 10
+)
+
+type frameWriter struct {
+	w    *bufio.Writer
+	hbuf bytes.Buffer
+	enc  *hpack.Encoder
+}
+
+func newFrameWriter(w io.Writer) *frameWriter {
+	fw := &frameWriter{w: bufio.NewWriter(w)}
+	fw.enc = hpack.NewEncoder(&fw.hbuf)
+	return fw
+}
+
+func (fw *frameWriter) writeFrame(t byte, p []byte) error {
+	if len(p) > maxFrameSize {
+		return fmt.Errorf("frame too large: %d bytes", len(p))
+	}
+
+	var hdr [frameHeaderLen]byte
+	hdr[0] = t
+	binary.BigEndian.PutUint32(hdr[1:], uint32(len(p)))
+	if _, err := fw.w.Write(hdr[:]); err != nil {
+		return err
+	}
+
+	_, err := fw.w.Write(p)
+	return err
+}
+
+// writeHeaders encodes pseudo (as key/value pairs) followed by h and writes
+// them as a single frame of type t.
+func (fw *frameWriter) writeHeaders(t byte, h http.Header, pseudo ...string) error {
+	fw.hbuf.Reset()
+	for i := 0; i+1 < len(pseudo); i += 2 {
+		fw.enc.WriteField(hpack.HeaderField{Name: pseudo[i], Value: pseudo[i+1]})
+	}
+
+	for k, vv := range h {
+		k = strings.ToLower(k)
+		for _, v := range vv {
+			fw.enc.WriteField(hpack.HeaderField{Name: k, Value: v})
+		}
+	}
+
+	return fw.writeFrame(t, fw.hbuf.Bytes())
+}
+
+func (fw *frameWriter) flush() error {
+	return fw.w.Flush()
+}
+
+type frameReader struct {
+	r      *bufio.Reader
+	dec    *hpack.Decoder
+	fields []hpack.HeaderField
+	size   uint32
+}
+
+func newFrameReader(r io.Reader) *frameReader {
+	fr := &frameReader{r: bufio.NewReader(r)}
+	fr.dec = hpack.NewDecoder(4096, func(f hpack.HeaderField) {
+		fr.size += f.Size()
+		if fr.size > maxHeaderListSize {
+			// stop keeping the fields, decodeHeaders fails once the
+			// block is decoded
+			fr.dec.SetEmitEnabled(false)
+			return
+		}
+
+		fr.fields = append(fr.fields, f)
+	})
+	fr.dec.SetMaxStringLength(maxHeaderListSize)
+	return fr
+}
+
+// readFrame returns the next frame of the stream. io.EOF is only returned
+// if the stream ends on a frame boundary.
+func (fr *frameReader) readFrame() (t byte, p []byte, err error) {
+	var hdr [frameHeaderLen]byte
+	if _, err = io.ReadFull(fr.r, hdr[:1]); err != nil {
+		return
+	}
+
+	if _, err = io.ReadFull(fr.r, hdr[1:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return
+	}
+
+	size := binary.BigEndian.Uint32(hdr[1:])
+	if size > maxFrameSize {
+		err = fmt.Errorf("frame too large: %d bytes", size)
+		return
+	}
+
+	// The payload is grown as it arrives rather than allocated from the
+	// announced size, so a peer cannot make us allocate maxFrameSize with a
+	// single header.
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, fr.r, int64(size))
+	if n < int64(size) && err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return hdr[0], buf.Bytes(), err
+}
+
+// decodeHeaders splits the header block p into its pseudo headers and
+// regular headers. Blocks larger than maxHeaderListSize once decoded are
+// rejected.
+func (fr *frameReader) decodeHeaders(p []byte) (pseudo map[string]string, h http.Header, err error) {
+	fr.fields = fr.fields[:0]
+	fr.size = 0
+	fr.dec.SetEmitEnabled(true)
+	if _, err = fr.dec.Write(p); err != nil {
+		return
+	}
+
+	if err = fr.dec.Close(); err != nil {
+		return
+	}
+
+	if fr.size > maxHeaderListSize {
+		err = fmt.Errorf("header list too large: more than %d bytes", maxHeaderListSize)
+		return
+	}
+
+	pseudo = make(map[string]string)
+	h = make(http.Header)
+	for _, f := range fr.fields {
+		if f.IsPseudo() {
+			pseudo[f.Name] = f.Value
+			continue
+		}
+
+		h.Add(http.CanonicalHeaderKey(f.Name), f.Value)
+	}
+
+	return
+}
+
+// dataReader exposes the DATA frames of a stream as an io.Reader. A
+// TRAILERS frame is handed to onTrailers and ends the body.
+type dataReader struct {
+	fr         *frameReader
+	buf        []byte
+	err        error
+	onTrailers func(http.Header)
+}
+
+func (d *dataReader) Read(b []byte) (n int, err error) {
+	for len(d.buf) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+
+		t, p, err := d.fr.readFrame()
+		if err != nil {
+			d.err = err
+			continue
+		}
+
+		switch t {
+		case frameData:
+			d.buf = p
+		case frameTrailers:
+			_, h, err := d.fr.decodeHeaders(p)
+			if err != nil {
+				d.err = err
+				continue
+			}
+
+			if d.onTrailers != nil {
+				d.onTrailers(h)
+			}
+			d.err = io.EOF
+		default:
+			d.err = fmt.Errorf("unexpected frame type 0x%x", t)
+		}
+	}
+
+	n = copy(b, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
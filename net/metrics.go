@@ -0,0 +1,338 @@
+package net
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	quicmetrics "github.com/gfanton/grpc-quic/metrics"
+	quic "github.com/lucas-clemente/quic-go"
+)
+
+// meter reports the measures of a listener or of a connection with its
+// labels, a nil meter does nothing.
+type meter struct {
+	m quicmetrics.Metrics
+	l quicmetrics.Labels
+}
+
+func newMeter(m quicmetrics.Metrics, l quicmetrics.Labels) *meter {
+	if m == nil {
+		return nil
+	}
+
+	return &meter{m, l}
+}
+
+func (m *meter) bytesRead(n int) {
+	if m != nil && n > 0 {
+		m.m.BytesRead(m.l, n)
+	}
+}
+
+func (m *meter) bytesWritten(n int) {
+	if m != nil && n > 0 {
+		m.m.BytesWritten(m.l, n)
+	}
+}
+
+func (m *meter) acceptFailed(errType string) {
+	if m != nil {
+		m.m.AcceptFailed(m.l, errType)
+	}
+}
+
+// errTypeRejected is the error type of the sessions rejected by an
+// admission hook.
+const errTypeRejected = "rejected"
+
+// listenerMeter returns the meter of the sessions accepted by ql.
+func listenerMeter(ql quic.Listener, m quicmetrics.Metrics) *meter {
+	return newMeter(m, quicmetrics.Labels{
+		Role:     quicmetrics.RoleListener,
+		Addr:     ql.Addr().String(),
+		Protocol: quicmetrics.ProtocolQUIC,
+	})
+}
+
+// handshake observes the handshake of sess, timed by t.
+func (m *meter) handshake(t *HandshakeTimer, sess quic.Session) {
+	if m == nil {
+		return
+	}
+
+	if d, ok := t.done(sess.RemoteAddr()); ok {
+		m.m.Handshake(m.l, d)
+	}
+}
+
+// HandshakeTimer times the handshakes of the sessions of a QUIC listener,
+// from the first packet of the client to the acceptance of the session:
+// quic-go only hands sessions over once their handshake is done. A nil
+// timer does nothing.
+type HandshakeTimer struct {
+	accept  func(net.Addr, *quic.Cookie) bool
+	timeout time.Duration
+
+	mu        sync.Mutex
+	starts    map[string]time.Time
+	lastPrune time.Time
+}
+
+// NewHandshakeTimer returns a timer and a copy of conf to listen with, which
+// lets the timer see the first packet of every handshake. conf may be nil.
+func NewHandshakeTimer(conf *quic.Config) (*HandshakeTimer, *quic.Config) {
+	if conf == nil {
+		conf = &quic.Config{}
+	} else {
+		c := *conf
+		conf = &c
+	}
+
+	t := &HandshakeTimer{
+		accept:    conf.AcceptCookie,
+		timeout:   conf.HandshakeTimeout,
+		starts:    make(map[string]time.Time),
+		lastPrune: time.Now(),
+	}
+
+	if t.accept == nil {
+		t.accept = acceptCookie
+	}
+
+	if t.timeout <= 0 {
+		t.timeout = defaultHandshakeTimeout
+	}
+
+	// quic-go only checks the cookies of the clients during their
+	// handshake, starting with their first packet
+	conf.AcceptCookie = func(addr net.Addr, cookie *quic.Cookie) bool {
+		t.start(addr)
+		return t.accept(addr, cookie)
+	}
+
+	return t, conf
+}
+
+// defaultHandshakeTimeout is the handshake timeout of quic-go, past which
+// the start of a handshake is forgotten.
+const defaultHandshakeTimeout = 10 * time.Second
+
+// cookieExpiry and acceptCookie mirror the default AcceptCookie of quic-go,
+// which is not exported: a cookie is valid for a day from the IP it was
+// issued to.
+const cookieExpiry = 24 * time.Hour
+
+func acceptCookie(addr net.Addr, cookie *quic.Cookie) bool {
+	if cookie == nil || time.Now().After(cookie.SentTime.Add(cookieExpiry)) {
+		return false
+	}
+
+	host := addr.String()
+	if udpAddr, ok := addr.(*net.UDPAddr); ok {
+		host = udpAddr.IP.String()
+	}
+
+	return host == cookie.RemoteAddr
+}
+
+func (t *HandshakeTimer) start(addr net.Addr) {
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// the handshakes which never completed are forgotten
+	if now.Sub(t.lastPrune) > t.timeout {
+		for key, start := range t.starts {
+			if now.Sub(start) > t.timeout {
+				delete(t.starts, key)
+			}
+		}
+		t.lastPrune = now
+	}
+
+	key := addr.String()
+	if start, ok := t.starts[key]; !ok || now.Sub(start) > t.timeout {
+		t.starts[key] = now
+	}
+}
+
+// done returns the duration of the handshake of the session with addr, ok
+// is false if its start is unknown.
+func (t *HandshakeTimer) done(addr net.Addr) (d time.Duration, ok bool) {
+	if t == nil {
+		return 0, false
+	}
+
+	key := addr.String()
+
+	t.mu.Lock()
+	start, ok := t.starts[key]
+	delete(t.starts, key)
+	t.mu.Unlock()
+
+	if !ok {
+		return 0, false
+	}
+
+	return time.Since(start), true
+}
+
+// watchSession reports sess as active until it is closed.
+func (m *meter) watchSession(sess quic.Session) {
+	if m == nil {
+		return
+	}
+
+	m.m.SessionOpened(m.l)
+	start := time.Now()
+	go func() {
+		<-sess.Context().Done()
+		m.m.SessionClosed(m.l, time.Since(start))
+	}()
+}
+
+// MeterConn reports the measures of conn to m with the labels l, until it
+// is closed. QUIC connections are measured in place, the others are wrapped.
+// conn is returned as is if m is nil.
+func MeterConn(conn net.Conn, m quicmetrics.Metrics, l quicmetrics.Labels) net.Conn {
+	mt := newMeter(m, l)
+	if mt == nil {
+		return conn
+	}
+
+	switch c := conn.(type) {
+	case *Conn:
+		c.stats.meter = mt
+		mt.watchSession(c.sess)
+		return c
+	case *StreamsConn:
+		c.stats.meter = mt
+		mt.watchSession(c.sess)
+		return c
+	}
+
+	m.SessionOpened(l)
+	return &meteredConn{Conn: conn, meter: mt, bytes: mt, start: time.Now()}
+}
+
+// meteredConn measures a connection which is not carried over QUIC.
+type meteredConn struct {
+	net.Conn
+
+	meter *meter
+	// bytes is nil once the bytes are counted by the connection secured
+	// over this one, see MeterHandshake
+	bytes *meter
+
+	start     time.Time
+	closeOnce sync.Once
+}
+
+func (c *meteredConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.bytes.bytesRead(n)
+	return n, err
+}
+
+func (c *meteredConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.bytes.bytesWritten(n)
+	return n, err
+}
+
+func (c *meteredConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.meter.m.SessionClosed(c.meter.l, time.Since(c.start))
+	})
+
+	return c.Conn.Close()
+}
+
+// MeterHandshake runs handshake on conn, such as a TLS handshake, and
+// counts the bytes of conn on the connection it returns: TCP connections are
+// measured above TLS, like QUIC connections are by the payload of their
+// streams. The session of conn is reported until conn is closed, and the
+// duration of the handshake is observed for the connections accepted by a
+// listener, dialers observe their whole dial. conn is handed to handshake as
+// is if it is not measured by MeterConn.
+func MeterHandshake(conn net.Conn, handshake func(net.Conn) (net.Conn, error)) (net.Conn, error) {
+	mc, ok := conn.(*meteredConn)
+	if !ok {
+		return handshake(conn)
+	}
+
+	mc.bytes = nil
+	start := time.Now()
+	secure, err := handshake(mc)
+	if err != nil {
+		return nil, err
+	}
+
+	if mc.meter.l.Role == quicmetrics.RoleListener {
+		mc.meter.m.Handshake(mc.meter.l, time.Since(start))
+	}
+
+	return &securedConn{Conn: secure, meter: mc.meter}, nil
+}
+
+// securedConn counts the bytes of a connection secured over a meteredConn,
+// which reports its session.
+type securedConn struct {
+	net.Conn
+
+	meter *meter
+}
+
+func (c *securedConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.meter.bytesRead(n)
+	return n, err
+}
+
+func (c *securedConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.meter.bytesWritten(n)
+	return n, err
+}
+
+// MeterListener reports the connections accepted by l and its accept
+// failures to m, labelled with the address of l and protocol. l is returned
+// as is if m is nil.
+func MeterListener(l net.Listener, m quicmetrics.Metrics, protocol string) net.Listener {
+	if m == nil {
+		return l
+	}
+
+	return &meteredListener{
+		Listener: l,
+		m:        m,
+		l: quicmetrics.Labels{
+			Role:     quicmetrics.RoleListener,
+			Addr:     l.Addr().String(),
+			Protocol: protocol,
+		},
+	}
+}
+
+type meteredListener struct {
+	net.Listener
+
+	m quicmetrics.Metrics
+	l quicmetrics.Labels
+}
+
+func (l *meteredListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		if !errors.Is(err, net.ErrClosed) {
+			l.m.AcceptFailed(l.l, ErrorType(err))
+		}
+
+		return nil, err
+	}
+
+	return MeterConn(conn, l.m, l.l), nil
+}
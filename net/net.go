@@ -1,49 +1,118 @@
 package net
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"net"
+	"sync"
 	"time"
 
+	quicmetrics "github.com/gfanton/grpc-quic/metrics"
 	quic "github.com/lucas-clemente/quic-go"
+	"google.golang.org/grpc/grpclog"
 )
 
-var _ net.Conn = (*Conn)(nil)
+var _ SessionConn = (*Conn)(nil)
 
 type Conn struct {
 	sess   quic.Session
 	stream quic.Stream
+
+	readDone  chan struct{}
+	readOnce  sync.Once
+	closeOnce sync.Once
+
+	stats connStats
 }
 
 func NewConn(sess quic.Session) (net.Conn, error) {
 	stream, err := sess.OpenStreamSync()
 	if err != nil {
-		return nil, err
+		return nil, WrapError(sess.RemoteAddr().String(), err)
+	}
+
+	return newConn(sess, stream), nil
+}
+
+func newConn(sess quic.Session, stream quic.Stream) *Conn {
+	return &Conn{
+		sess:     sess,
+		stream:   stream,
+		readDone: make(chan struct{}),
 	}
+}
 
-	return &Conn{sess, stream}, nil
+// Session returns the underlying QUIC session.
+func (c *Conn) Session() quic.Session {
+	return c.sess
+}
+
+// VersionTLS is the QUIC version handshaking with TLS 1.3, which quic-go
+// supports without exporting it. Unlike gQUIC, it carries client
+// certificates.
+const VersionTLS quic.VersionNumber = 101
+
+// ConnectionState returns the TLS state of sess, in the shape of the state of
+// a TLS connection. QUIC only exposes whether the handshake completed, the
+// server name and the peer certificates, and Version is only set for QUIC
+// versions handshaking with TLS 1.3. VerifiedChains is set on the server
+// side once a listener verified the certificate of the client, see
+// ListenerConfig.TLSConfig. The other fields are left empty.
+func ConnectionState(sess quic.Session) tls.ConnectionState {
+	state := sess.ConnectionState()
+	cs := tls.ConnectionState{
+		HandshakeComplete: state.HandshakeComplete,
+		ServerName:        state.ServerName,
+		PeerCertificates:  state.PeerCertificates,
+	}
+
+	if vs, ok := sess.(*verifiedSession); ok {
+		cs.VerifiedChains = vs.chains
+	}
+
+	if v, ok := Version(sess); ok && v.UsesTLS() {
+		cs.Version = tls.VersionTLS13
+	}
+
+	return cs
+}
+
+// Version returns the QUIC version negotiated by sess, ok is false if the
+// session does not expose it.
+func Version(sess quic.Session) (v quic.VersionNumber, ok bool) {
+	if vs, ok := sess.(interface{ GetVersion() quic.VersionNumber }); ok {
+		return vs.GetVersion(), true
+	}
+
+	return
 }
 
 // Read reads data from the connection.
 // Read can be made to time out and return an Error with Timeout() == true
 // after a fixed time limit; see SetDeadline and SetReadDeadline.
 func (c *Conn) Read(b []byte) (n int, err error) {
-	return c.stream.Read(b)
+	n, err = c.stream.Read(b)
+	c.stats.addRead(n)
+	if err != nil {
+		c.readFailed(err)
+		err = WrapError(c.sess.RemoteAddr().String(), err)
+	}
+
+	return n, err
 }
 
 // Write writes data to the connection.
 // Write can be made to time out and return an Error with Timeout() == true
 // after a fixed time limit; see SetDeadline and SetWriteDeadline.
 func (c *Conn) Write(b []byte) (n int, err error) {
-	return c.stream.Write(b)
-}
-
-// Close closes the connection.
-// Any blocked Read or Write operations will be unblocked and return errors.
-func (c *Conn) Close() error {
-	// @TODO: log this
-	c.stream.Close()
+	n, err = c.stream.Write(b)
+	c.stats.addWritten(n)
+	if err != nil {
+		err = WrapError(c.sess.RemoteAddr().String(), err)
+	}
 
-	return c.sess.Close()
+	return n, err
 }
 
 // LocalAddr returns the local network address.
@@ -92,37 +161,333 @@ func (c *Conn) SetWriteDeadline(t time.Time) error {
 	return c.stream.SetWriteDeadline(t)
 }
 
+// DefaultAcceptTimeout is the time a new session has to open its first
+// stream before being dropped by the Listener.
+const DefaultAcceptTimeout = 10 * time.Second
+
+// DefaultAcceptBacklog is the number of ready connections queued until
+// Accept is called.
+const DefaultAcceptBacklog = 64
+
+// ErrAcceptTimeout is reported when a session did not open its first stream
+// within the accept timeout.
+var ErrAcceptTimeout = errors.New("session did not open a stream in time")
+
+var (
+	errListenerClosed = errors.New("listener closed")
+	errShuttingDown   = errors.New("server shutting down")
+)
+
+// ListenerConfig configures a Listener.
+type ListenerConfig struct {
+	// AcceptTimeout bounds the time a new session may take to open its
+	// first stream. If zero, DefaultAcceptTimeout is used.
+	AcceptTimeout time.Duration
+
+	// AcceptBacklog is the number of ready connections queued until Accept
+	// is called. If zero, DefaultAcceptBacklog is used.
+	AcceptBacklog int
+
+	// OnAcceptError is called with every session dropped before being
+	// handed to Accept. If nil, the error is logged.
+	OnAcceptError func(remote net.Addr, err error)
+
+	// TLSConfig, if set, is the TLS config the sessions were accepted with.
+	// QUIC handshakes only request client certificates, so its ClientAuth,
+	// ClientCAs and VerifyPeerCertificate are enforced once the handshake
+	// completed, and the sessions failing them are closed with
+	// AuthFailureCode before being admitted.
+	TLSConfig *tls.Config
+
+	// Admission, if set, is called with every new session before it opens
+	// any stream, and closes the sessions it rejects.
+	Admission AdmissionFunc
+
+	// Registry, if set, keeps the sessions served by the listener.
+	Registry *Registry
+
+	// Metrics, if set, receives the measures of the listener and of its
+	// sessions.
+	Metrics quicmetrics.Metrics
+
+	// Handshakes, if set, times the handshakes of the sessions observed by
+	// Metrics. ql must listen with the config of NewHandshakeTimer.
+	Handshakes *HandshakeTimer
+}
+
 var _ net.Listener = (*Listener)(nil)
 
+// Listener accepts sessions in the background: each session waits for its
+// first stream on its own, so a peer that never opens one cannot stall the
+// others, and ready connections are queued until Accept.
 type Listener struct {
-	ql quic.Listener
+	ql    quic.Listener
+	cfg   ListenerConfig
+	meter *meter
+
+	conns     chan *Conn
+	closed    chan struct{}
+	closeOnce sync.Once
+	err       error
+
+	mu       sync.Mutex
+	live     map[*Conn]struct{}
+	shutdown bool
+	drained  chan struct{}
 }
 
+// Listen returns a Listener with the default configuration.
 func Listen(ql quic.Listener) net.Listener {
-	return &Listener{ql}
+	return NewListener(ql, nil)
 }
 
-// Accept waits for and returns the next connection to the listener.
-func (l *Listener) Accept() (net.Conn, error) {
-	sess, err := l.ql.Accept()
-	if err != nil {
-		return nil, err
+// NewListener starts accepting sessions on ql.
+func NewListener(ql quic.Listener, cfg *ListenerConfig) *Listener {
+	l := &Listener{
+		ql:     ql,
+		closed: make(chan struct{}),
+		live:   make(map[*Conn]struct{}),
+	}
+
+	if cfg != nil {
+		l.cfg = *cfg
+	}
+
+	if l.cfg.AcceptTimeout <= 0 {
+		l.cfg.AcceptTimeout = DefaultAcceptTimeout
+	}
+
+	if l.cfg.AcceptBacklog <= 0 {
+		l.cfg.AcceptBacklog = DefaultAcceptBacklog
+	}
+
+	l.meter = listenerMeter(ql, l.cfg.Metrics)
+
+	l.conns = make(chan *Conn, l.cfg.AcceptBacklog)
+
+	go l.acceptLoop()
+	return l
+}
+
+func (l *Listener) acceptLoop() {
+	for {
+		sess, err := l.ql.Accept()
+		if err != nil {
+			l.closeWithError(err)
+			return
+		}
+
+		l.meter.handshake(l.cfg.Handshakes, sess)
+		go l.handleSession(sess)
+	}
+}
+
+// handleSession authenticates and admits sess, waits for its first stream
+// and queues the resulting connection.
+func (l *Listener) handleSession(sess quic.Session) {
+	sess, err := authenticate(l.cfg.TLSConfig, sess)
+	if err == nil {
+		err = admit(l.cfg.Admission, sess)
 	}
 
-	s, err := sess.AcceptStream()
 	if err != nil {
-		return nil, err
+		reportAcceptError(&l.cfg, l.meter, sess, errTypeRejected, err)
+		return
+	}
+
+	type result struct {
+		stream quic.Stream
+		err    error
+	}
+
+	ready := make(chan result, 1)
+	go func() {
+		stream, err := sess.AcceptStream()
+		ready <- result{stream, err}
+	}()
+
+	timer := time.NewTimer(l.cfg.AcceptTimeout)
+	defer timer.Stop()
+
+	var conn *Conn
+	select {
+	case res := <-ready:
+		if res.err != nil {
+			l.reportError(sess, res.err)
+			sess.Close()
+			return
+		}
+
+		conn = newConn(sess, res.stream)
+	case <-timer.C:
+		l.reportError(sess, ErrAcceptTimeout)
+		sess.CloseWithError(IdleCode, ErrAcceptTimeout)
+		return
+	case <-l.closed:
+		sess.CloseWithError(ShutdownCode, errListenerClosed)
+		return
+	}
+
+	if !l.track(conn) {
+		conn.abort(ShutdownCode, errListenerClosed.Error())
+		return
+	}
+
+	conn.stats.meter = l.meter
+	l.meter.watchSession(sess)
+	l.cfg.Registry.add(sess, false, &conn.stats, conn.abort)
+
+	select {
+	case l.conns <- conn:
+		// Close may have dropped the backlog just before conn was queued.
+		select {
+		case <-l.closed:
+			l.dropBacklog()
+		default:
+		}
+	case <-l.closed:
+		conn.abort(ShutdownCode, errListenerClosed.Error())
+	}
+}
+
+// track registers conn until its session is closed, unless the listener is
+// closed.
+func (l *Listener) track(conn *Conn) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	select {
+	case <-l.closed:
+		return false
+	default:
+	}
+
+	l.live[conn] = struct{}{}
+	go func() {
+		<-conn.sess.Context().Done()
+
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		delete(l.live, conn)
+		if len(l.live) == 0 && l.drained != nil {
+			close(l.drained)
+			l.drained = nil
+		}
+	}()
+
+	return true
+}
+
+func (l *Listener) reportError(sess quic.Session, err error) {
+	reportAcceptError(&l.cfg, l.meter, sess, ErrorType(err), err)
+}
+
+// reportAcceptError reports a session dropped before being served.
+func reportAcceptError(cfg *ListenerConfig, m *meter, sess quic.Session, errType string, err error) {
+	m.acceptFailed(errType)
+	if cfg.OnAcceptError != nil {
+		cfg.OnAcceptError(sess.RemoteAddr(), err)
+		return
 	}
 
-	return &Conn{sess, s}, nil
+	grpclog.Warningf("quic: dropping session from %s: %v", sess.RemoteAddr(), err)
+}
+
+func (l *Listener) closeWithError(err error) {
+	l.closeOnce.Do(func() {
+		l.err = err
+		close(l.closed)
+	})
+}
+
+// Accept waits for and returns the next connection to the listener.
+func (l *Listener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, l.err
+	}
 }
 
-// Close closes the listener.
+// Close closes the listener and every session it accepted.
 // Any blocked Accept operations will be unblocked and return errors.
+// While Shutdown runs, Close only stops accepting sessions.
 func (l *Listener) Close() error {
+	l.closeWithError(errListenerClosed)
+	l.dropBacklog()
+
+	l.mu.Lock()
+	shutdown := l.shutdown
+	l.mu.Unlock()
+
+	if shutdown {
+		return nil
+	}
+
 	return l.ql.Close()
 }
 
+// Shutdown stops accepting sessions and waits for the connections already
+// accepted to be closed, which gRPC does once their calls are done after it
+// sent GOAWAY from grpc.Server.GracefulStop. When ctx is done first, the
+// remaining sessions are closed with ShutdownCode and ctx.Err() is returned.
+// The listener is closed once all its sessions are.
+func (l *Listener) Shutdown(ctx context.Context) error {
+	l.mu.Lock()
+	l.shutdown = true
+	l.mu.Unlock()
+
+	l.closeWithError(errListenerClosed)
+	l.dropBacklog()
+
+	drained := make(chan struct{})
+	l.mu.Lock()
+	if len(l.live) == 0 {
+		close(drained)
+	} else {
+		l.drained = drained
+	}
+	l.mu.Unlock()
+
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+
+		l.mu.Lock()
+		conns := make([]*Conn, 0, len(l.live))
+		for conn := range l.live {
+			conns = append(conns, conn)
+		}
+		l.mu.Unlock()
+
+		for _, conn := range conns {
+			conn.abort(ShutdownCode, errShuttingDown.Error())
+		}
+	}
+
+	if cerr := l.ql.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// dropBacklog closes the connections that were never accepted.
+func (l *Listener) dropBacklog() {
+	for {
+		select {
+		case conn := <-l.conns:
+			conn.abort(ShutdownCode, errListenerClosed.Error())
+		default:
+			return
+		}
+	}
+}
+
 // Addr returns the listener's network address.
 func (l *Listener) Addr() net.Addr {
 	return l.ql.Addr()
@@ -0,0 +1,182 @@
+package net
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	quic "github.com/lucas-clemente/quic-go"
+)
+
+// ErrUnknownConnection is returned for an ID which is not in a Registry,
+// either because it never was or because its session is closed.
+var ErrUnknownConnection = errors.New("unknown connection")
+
+// ConnInfo describes a live session of a Registry.
+type ConnInfo struct {
+	// ID identifies the session in its registry.
+	ID uint64
+
+	LocalAddr  net.Addr
+	RemoteAddr net.Addr
+
+	// Start is the time the session was admitted.
+	Start time.Time
+
+	// Protocol is the negotiated QUIC version, such as "gQUIC 43".
+	Protocol string
+
+	// NativeStreams is true if every call of the session is mapped on its
+	// own stream.
+	NativeStreams bool
+
+	// State is the TLS state of the session, see ConnectionState.
+	State tls.ConnectionState
+
+	// BytesRead and BytesWritten count the payload read from and written to
+	// the streams of the session.
+	BytesRead    uint64
+	BytesWritten uint64
+}
+
+// connStats counts the bytes of a session, it is updated atomically. The
+// bytes are also reported to meter if set.
+type connStats struct {
+	read    uint64
+	written uint64
+
+	meter *meter
+}
+
+func (s *connStats) addRead(n int) {
+	atomic.AddUint64(&s.read, uint64(n))
+	s.meter.bytesRead(n)
+}
+
+func (s *connStats) addWritten(n int) {
+	atomic.AddUint64(&s.written, uint64(n))
+	s.meter.bytesWritten(n)
+}
+
+// Registry keeps the sessions served by listeners, from the time they are
+// handed to the server until they are closed. It is shared by the listeners
+// given it through ListenerConfig, and safe for concurrent use.
+type Registry struct {
+	mu      sync.Mutex
+	lastID  uint64
+	entries map[uint64]*registryEntry
+}
+
+type registryEntry struct {
+	info  ConnInfo
+	stats *connStats
+	close func(code quic.ErrorCode, reason string) error
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{entries: make(map[uint64]*registryEntry)}
+}
+
+// add registers sess until it is closed. close closes it on behalf of an
+// operator. It does nothing on a nil registry.
+func (r *Registry) add(sess quic.Session, native bool, stats *connStats, close func(quic.ErrorCode, string) error) {
+	if r == nil {
+		return
+	}
+
+	info := ConnInfo{
+		LocalAddr:     sess.LocalAddr(),
+		RemoteAddr:    sess.RemoteAddr(),
+		Start:         time.Now(),
+		NativeStreams: native,
+		State:         ConnectionState(sess),
+	}
+
+	if v, ok := Version(sess); ok {
+		info.Protocol = v.String()
+	}
+
+	r.mu.Lock()
+	r.lastID++
+	info.ID = r.lastID
+	r.entries[info.ID] = &registryEntry{info: info, stats: stats, close: close}
+	r.mu.Unlock()
+
+	go func() {
+		<-sess.Context().Done()
+
+		r.mu.Lock()
+		delete(r.entries, info.ID)
+		r.mu.Unlock()
+	}()
+}
+
+func (e *registryEntry) snapshot() ConnInfo {
+	info := e.info
+	info.BytesRead = atomic.LoadUint64(&e.stats.read)
+	info.BytesWritten = atomic.LoadUint64(&e.stats.written)
+	return info
+}
+
+// Connections returns the live sessions, sorted by ID.
+func (r *Registry) Connections() []ConnInfo {
+	r.mu.Lock()
+	infos := make([]ConnInfo, 0, len(r.entries))
+	for _, e := range r.entries {
+		infos = append(infos, e.snapshot())
+	}
+	r.mu.Unlock()
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// Connection returns the live session id, ok is false if it is unknown.
+func (r *Registry) Connection(id uint64) (info ConnInfo, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.entries[id]
+	if !ok {
+		return ConnInfo{}, false
+	}
+
+	return e.snapshot(), true
+}
+
+// Close closes the session id at once, sending code and reason to the
+// peer. It returns ErrUnknownConnection if the session is unknown.
+func (r *Registry) Close(id uint64, code quic.ErrorCode, reason string) error {
+	r.mu.Lock()
+	e, ok := r.entries[id]
+	r.mu.Unlock()
+
+	if !ok {
+		return ErrUnknownConnection
+	}
+
+	return e.close(code, reason)
+}
+
+// countingStream counts the bytes read from and written to a stream.
+type countingStream struct {
+	quic.Stream
+	stats *connStats
+}
+
+func (s *countingStream) Read(b []byte) (int, error) {
+	n, err := s.Stream.Read(b)
+	s.stats.addRead(n)
+	return n, err
+}
+
+func (s *countingStream) Write(b []byte) (int, error) {
+	n, err := s.Stream.Write(b)
+	s.stats.addWritten(n)
+	return n, err
+}
@@ -0,0 +1,620 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	quic "github.com/lucas-clemente/quic-go"
+	"golang.org/x/net/http2"
+)
+
+// streamCanceledCode is used to abort a stream whose call has been canceled.
+const streamCanceledCode quic.ErrorCode = 0x8
+
+// SessionConn is implemented by connections carried over a QUIC session.
+type SessionConn interface {
+	net.Conn
+
+	// Session returns the underlying QUIC session.
+	Session() quic.Session
+}
+
+var _ SessionConn = (*StreamsConn)(nil)
+
+// StreamsConn is the client side of a session where every gRPC call is
+// mapped on its own bidirectional QUIC stream. gRPC speaks HTTP/2 to an
+// in-process endpoint and each request is forwarded on a new stream of the
+// shared session, so a lost packet only blocks the call it belongs to.
+type StreamsConn struct {
+	net.Conn
+	sess quic.Session
+
+	stats connStats
+}
+
+// NewStreamsConn returns a net.Conn carrying HTTP/2 from gRPC, where each
+// request is forwarded on its own stream of sess.
+func NewStreamsConn(sess quic.Session) (net.Conn, error) {
+	local, remote := net.Pipe()
+
+	go func() {
+		srv := &http2.Server{}
+		srv.ServeConn(remote, &http2.ServeConnOpts{
+			Handler: &streamProxy{sess},
+		})
+
+		sess.Close()
+	}()
+
+	go func() {
+		<-sess.Context().Done()
+		remote.Close()
+	}()
+
+	return &StreamsConn{Conn: local, sess: sess}, nil
+}
+
+// Read reads the HTTP/2 data of the responses.
+func (c *StreamsConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.stats.addRead(n)
+	return n, err
+}
+
+// Write writes the HTTP/2 data of the requests.
+func (c *StreamsConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.stats.addWritten(n)
+	return n, err
+}
+
+// Session returns the underlying QUIC session.
+func (c *StreamsConn) Session() quic.Session {
+	return c.sess
+}
+
+// LocalAddr returns the local network address.
+func (c *StreamsConn) LocalAddr() net.Addr {
+	return c.sess.LocalAddr()
+}
+
+// RemoteAddr returns the remote network address.
+func (c *StreamsConn) RemoteAddr() net.Addr {
+	return c.sess.RemoteAddr()
+}
+
+// streamProxy forwards every HTTP/2 request on a new stream of sess.
+type streamProxy struct {
+	sess quic.Session
+}
+
+func (p *streamProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	stream, err := p.sess.OpenStreamSync()
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	var (
+		mu      sync.Mutex
+		wclosed bool
+	)
+
+	// closeWrite ends the request side of the stream, either gracefully or
+	// by aborting it, but only once. Only the body goroutine writes on the
+	// stream, so Close never races with Write.
+	closeWrite := func(abort bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if wclosed {
+			return
+		}
+
+		wclosed = true
+		if abort {
+			stream.CancelWrite(streamCanceledCode)
+			return
+		}
+		stream.Close()
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-r.Context().Done():
+			mu.Lock()
+			stream.CancelRead(streamCanceledCode)
+			mu.Unlock()
+
+			closeWrite(true)
+		case <-done:
+		}
+	}()
+
+	fw := newFrameWriter(stream)
+	err = fw.writeHeaders(frameHeaders, r.Header,
+		":method", r.Method,
+		":path", r.URL.RequestURI(),
+		":authority", r.Host,
+	)
+	if err == nil {
+		err = fw.flush()
+	}
+	if err != nil {
+		closeWrite(true)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	go func() {
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := r.Body.Read(buf)
+			if n > 0 {
+				werr := fw.writeFrame(frameData, buf[:n])
+				if werr == nil {
+					werr = fw.flush()
+				}
+
+				if werr != nil {
+					closeWrite(true)
+					return
+				}
+			}
+
+			if err != nil {
+				closeWrite(err != io.EOF)
+				return
+			}
+		}
+	}()
+
+	fr := newFrameReader(stream)
+	t, b, err := fr.readFrame()
+	if err != nil || t != frameHeaders {
+		panic(http.ErrAbortHandler)
+	}
+
+	pseudo, h, err := fr.decodeHeaders(b)
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+
+	code, err := strconv.Atoi(pseudo[":status"])
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+
+	for k, vv := range h {
+		w.Header()[k] = vv
+	}
+	w.WriteHeader(code)
+	w.(http.Flusher).Flush()
+
+	body := &dataReader{
+		fr: fr,
+		onTrailers: func(trailers http.Header) {
+			for k, vv := range trailers {
+				w.Header()[http2.TrailerPrefix+k] = vv
+			}
+		},
+	}
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			w.Write(buf[:n])
+			w.(http.Flusher).Flush()
+		}
+
+		if err == io.EOF {
+			return
+		}
+
+		if err != nil {
+			panic(http.ErrAbortHandler)
+		}
+	}
+}
+
+// ServeStreams serves every bidirectional stream opened by the peer on sess
+// as a single gRPC call on h, until the session is closed.
+func ServeStreams(sess quic.Session, h http.Handler) error {
+	for {
+		stream, err := sess.AcceptStream()
+		if err != nil {
+			return err
+		}
+
+		go serveStream(sess, stream, h)
+	}
+}
+
+func serveStream(sess quic.Session, stream quic.Stream, h http.Handler) {
+	fr := newFrameReader(stream)
+	t, b, err := fr.readFrame()
+	if err == nil && t != frameHeaders {
+		err = io.ErrUnexpectedEOF
+	}
+
+	var (
+		pseudo map[string]string
+		header http.Header
+	)
+
+	if err == nil {
+		pseudo, header, err = fr.decodeHeaders(b)
+	}
+
+	if err != nil {
+		stream.CancelRead(streamCanceledCode)
+		stream.CancelWrite(streamCanceledCode)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(sess.Context())
+	defer cancel()
+
+	r := newStreamRequest(ctx, sess, pseudo, header)
+	r.Body = &streamBody{dataReader{fr: fr}, cancel}
+
+	w := &streamResponseWriter{
+		ctx:    ctx,
+		stream: stream,
+		fw:     newFrameWriter(stream),
+		header: make(http.Header),
+	}
+
+	h.ServeHTTP(w, r)
+
+	if err := w.finish(); err != nil {
+		stream.CancelWrite(streamCanceledCode)
+	}
+}
+
+func newStreamRequest(ctx context.Context, sess quic.Session, pseudo map[string]string, header http.Header) *http.Request {
+	u, err := url.ParseRequestURI(pseudo[":path"])
+	if err != nil {
+		u = &url.URL{Path: pseudo[":path"]}
+	}
+
+	r := &http.Request{
+		Method:     pseudo[":method"],
+		URL:        u,
+		RequestURI: pseudo[":path"],
+		Proto:      "HTTP/2.0",
+		ProtoMajor: 2,
+		Header:     header,
+		Host:       pseudo[":authority"],
+		RemoteAddr: sess.RemoteAddr().String(),
+	}
+
+	state := ConnectionState(sess)
+	r.TLS = &state
+
+	return r.WithContext(ctx)
+}
+
+// streamBody is the request body of a call served on a stream. Any error
+// other than io.EOF means the call was aborted by the peer.
+type streamBody struct {
+	dataReader
+	cancel context.CancelFunc
+}
+
+func (b *streamBody) Read(p []byte) (int, error) {
+	n, err := b.dataReader.Read(p)
+	if err != nil && err != io.EOF {
+		b.cancel()
+	}
+
+	return n, err
+}
+
+func (b *streamBody) Close() error {
+	return nil
+}
+
+var (
+	_ http.Flusher       = (*streamResponseWriter)(nil)
+	_ http.CloseNotifier = (*streamResponseWriter)(nil)
+)
+
+// streamResponseWriter writes a response as HEADERS, DATA and TRAILERS
+// frames on a QUIC stream.
+type streamResponseWriter struct {
+	ctx    context.Context
+	stream quic.Stream
+	fw     *frameWriter
+	header http.Header
+
+	wroteHeader bool
+	err         error
+}
+
+func (w *streamResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *streamResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	h := make(http.Header, len(w.header))
+	for k, vv := range w.header {
+		if k == "Trailer" || strings.HasPrefix(k, http2.TrailerPrefix) {
+			continue
+		}
+		h[k] = vv
+	}
+
+	w.setErr(w.fw.writeHeaders(frameHeaders, h, ":status", strconv.Itoa(code)))
+}
+
+func (w *streamResponseWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	w.setErr(w.fw.writeFrame(frameData, p))
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	return len(p), nil
+}
+
+func (w *streamResponseWriter) Flush() {
+	w.WriteHeader(http.StatusOK)
+	if w.err == nil {
+		w.setErr(w.fw.flush())
+	}
+}
+
+func (w *streamResponseWriter) CloseNotify() <-chan bool {
+	ch := make(chan bool, 1)
+	go func() {
+		<-w.ctx.Done()
+		ch <- true
+	}()
+
+	return ch
+}
+
+// finish sends the trailers, either declared through the "Trailer" header or
+// set with http2.TrailerPrefix, and closes the stream.
+func (w *streamResponseWriter) finish() error {
+	w.WriteHeader(http.StatusOK)
+	if w.err != nil {
+		return w.err
+	}
+
+	trailers := make(http.Header)
+	for _, k := range w.header["Trailer"] {
+		k = http.CanonicalHeaderKey(k)
+		if vv, ok := w.header[k]; ok {
+			trailers[k] = vv
+		}
+	}
+
+	for k, vv := range w.header {
+		if strings.HasPrefix(k, http2.TrailerPrefix) {
+			trailers[http.CanonicalHeaderKey(strings.TrimPrefix(k, http2.TrailerPrefix))] = vv
+		}
+	}
+
+	if len(trailers) > 0 {
+		w.setErr(w.fw.writeHeaders(frameTrailers, trailers))
+	}
+
+	if w.err == nil {
+		w.setErr(w.fw.flush())
+	}
+
+	if w.err != nil {
+		return w.err
+	}
+
+	return w.stream.Close()
+}
+
+func (w *streamResponseWriter) setErr(err error) {
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+var _ net.Listener = (*StreamsListener)(nil)
+
+// StreamsListener serves the sessions of a quic.Listener in streams mode.
+// It never returns connections: Accept serves every incoming stream as a
+// gRPC call on the handler and only returns once the listener is closed.
+// With grpc.Server.ServeHTTP as the handler, the settings of the gRPC
+// transport, such as its credentials, MaxConcurrentStreams, keepalive and tap
+// handle, do not apply: the session is secured and limited by QUIC, see
+// opts.NativeStreams.
+type StreamsListener struct {
+	ql    quic.Listener
+	h     http.Handler
+	cfg   ListenerConfig
+	meter *meter
+
+	mu       sync.Mutex
+	draining bool
+	active   int
+	calls    sync.WaitGroup
+	sessions map[quic.Session]struct{}
+}
+
+// streamsFlushDelay is the time given to the last responses to reach the
+// peers before GracefulClose closes the sessions, quic-go dropping any data
+// not yet sent on close.
+const streamsFlushDelay = 200 * time.Millisecond
+
+// ListenStreams returns a listener serving every stream of ql on h.
+func ListenStreams(ql quic.Listener, h http.Handler) net.Listener {
+	return NewStreamsListener(ql, h, nil)
+}
+
+// NewStreamsListener returns a listener serving every stream of ql on h.
+// Only the TLSConfig, Admission, OnAcceptError, Registry, Metrics and
+// Handshakes fields of cfg apply, sessions are served as soon as they are
+// admitted.
+func NewStreamsListener(ql quic.Listener, h http.Handler, cfg *ListenerConfig) *StreamsListener {
+	l := &StreamsListener{ql: ql, h: h, sessions: make(map[quic.Session]struct{})}
+	if cfg != nil {
+		l.cfg = *cfg
+	}
+
+	l.meter = listenerMeter(ql, l.cfg.Metrics)
+	return l
+}
+
+// Accept serves incoming sessions until the listener is closed.
+func (l *StreamsListener) Accept() (net.Conn, error) {
+	for {
+		sess, err := l.ql.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		l.meter.handshake(l.cfg.Handshakes, sess)
+		go l.serveSession(sess)
+	}
+}
+
+func (l *StreamsListener) serveSession(sess quic.Session) {
+	sess, err := authenticate(l.cfg.TLSConfig, sess)
+	if err == nil {
+		err = admit(l.cfg.Admission, sess)
+	}
+
+	if err != nil {
+		reportAcceptError(&l.cfg, l.meter, sess, errTypeRejected, err)
+		return
+	}
+
+	l.mu.Lock()
+	l.sessions[sess] = struct{}{}
+	l.mu.Unlock()
+
+	stats := &connStats{meter: l.meter}
+	l.meter.watchSession(sess)
+	l.cfg.Registry.add(sess, true, stats, func(code quic.ErrorCode, reason string) error {
+		return sess.CloseWithError(code, errors.New(reason))
+	})
+
+	defer func() {
+		l.mu.Lock()
+		delete(l.sessions, sess)
+		l.mu.Unlock()
+	}()
+
+	for {
+		stream, err := sess.AcceptStream()
+		if err != nil {
+			return
+		}
+
+		if !l.startCall() {
+			stream.CancelRead(streamCanceledCode)
+			stream.CancelWrite(streamCanceledCode)
+			continue
+		}
+
+		go func() {
+			defer l.endCall()
+			serveStream(sess, &countingStream{stream, stats}, l.h)
+		}()
+	}
+}
+
+// startCall registers a new call, unless the listener is draining.
+func (l *StreamsListener) startCall() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.draining {
+		return false
+	}
+
+	l.active++
+	l.calls.Add(1)
+	return true
+}
+
+func (l *StreamsListener) endCall() {
+	l.mu.Lock()
+	l.active--
+	l.mu.Unlock()
+
+	l.calls.Done()
+}
+
+// GracefulClose refuses new streams, waits for the calls in flight to
+// complete and closes the listener.
+func (l *StreamsListener) GracefulClose() error {
+	return l.Shutdown(context.Background())
+}
+
+// Shutdown refuses new streams, waits for the calls in flight to complete
+// and closes the listener. When ctx is done first, the sessions are closed
+// with ShutdownCode and ctx.Err() is returned.
+func (l *StreamsListener) Shutdown(ctx context.Context) error {
+	l.mu.Lock()
+	l.draining = true
+	active := l.active
+	l.mu.Unlock()
+
+	var err error
+	if active > 0 {
+		done := make(chan struct{})
+		go func() {
+			l.calls.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			time.Sleep(streamsFlushDelay)
+		case <-ctx.Done():
+			err = ctx.Err()
+
+			l.mu.Lock()
+			for sess := range l.sessions {
+				sess.CloseWithError(ShutdownCode, errShuttingDown)
+			}
+			l.mu.Unlock()
+		}
+	}
+
+	if cerr := l.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// Close closes the listener and every session it accepted.
+// Any blocked Accept operations will be unblocked and return errors.
+func (l *StreamsListener) Close() error {
+	return l.ql.Close()
+}
+
+// Addr returns the listener's network address.
+func (l *StreamsListener) Addr() net.Addr {
+	return l.ql.Addr()
+}
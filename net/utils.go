@@ -1,39 +1,216 @@
 package net
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
 
 	ma "github.com/multiformats/go-multiaddr"
-	manet "github.com/multiformats/go-multiaddr-net"
+	madns "github.com/multiformats/go-multiaddr-dns"
 )
 
-func ParseMultiaddr(m ma.Multiaddr) (laddr string, code int, err error) {
-	if !manet.IsThinWaist(m) {
-		err = fmt.Errorf("%s is not a 'thin waist' address", m)
-		return
+// DNSProtocol is the /dns protocol, which resolves to both IPv4 and IPv6
+// addresses. Unlike /dns4 and /dns6 it is not provided by go-multiaddr-dns.
+var DNSProtocol = ma.Protocol{
+	Code:       53,
+	Size:       ma.LengthPrefixedVarSize,
+	Name:       "dns",
+	VCode:      ma.CodeToVarint(53),
+	Transcoder: madns.DnsTranscoder,
+}
+
+func init() {
+	if err := ma.AddProtocol(DNSProtocol); err != nil {
+		panic(fmt.Errorf("error registering dns protocol: %s", err))
 	}
+}
+
+// Resolver looks up the IP addresses of a host, net.DefaultResolver is used
+// when none is given.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+var errMissingTransport = errors.New("missing transport")
+
+type hostport struct {
+	hostCode int
+	host     string
+	port     string
+	code     int
+}
+
+func splitMultiaddr(m ma.Multiaddr) (hp hostport, err error) {
+	var zone string
 
-	var addr string
-	var port string
-	for _, p := range m.Protocols() {
-		switch p.Code {
-		case ma.P_IP4:
-			addr, err = m.ValueForProtocol(ma.P_IP4)
-		case ma.P_UDP:
-			code = ma.P_UDP
-			port, err = m.ValueForProtocol(ma.P_UDP)
-		case ma.P_TCP:
-			code = ma.P_TCP
-			port, err = m.ValueForProtocol(ma.P_TCP)
+	ma.ForEach(m, func(c ma.Component) bool {
+		p := c.Protocol()
+		switch {
+		case hp.code != 0 && p.Code == CertHashProtocol.Code:
+			// certificate pins follow the transport
+		case hp.code != 0:
+			err = &MultiaddrError{Addr: m.String(), Err: fmt.Errorf("unexpected `%s` after transport", p.Name)}
+		case p.Code == ma.P_IP6ZONE && hp.hostCode == 0:
+			zone = c.Value()
+		case hp.hostCode == 0:
+			switch p.Code {
+			case ma.P_IP4, ma.P_IP6, DNSProtocol.Code, madns.Dns4Protocol.Code, madns.Dns6Protocol.Code:
+				hp.hostCode, hp.host = p.Code, c.Value()
+			default:
+				err = &UnsupportedProtocolError{Addr: m.String(), Protocol: p.Name}
+			}
+		case p.Code == ma.P_UDP || p.Code == ma.P_TCP:
+			hp.code, hp.port = p.Code, c.Value()
 		default:
-			err = fmt.Errorf("not supported `%s`", p.Name)
+			err = &UnsupportedProtocolError{Addr: m.String(), Protocol: p.Name}
 		}
 
-		if err != nil {
+		return err == nil
+	})
+
+	if err != nil {
+		return
+	}
+
+	if hp.code == 0 {
+		err = &MultiaddrError{Addr: m.String(), Err: errMissingTransport}
+		return
+	}
+
+	if zone != "" {
+		if hp.hostCode != ma.P_IP6 {
+			err = &MultiaddrError{Addr: m.String(), Err: errors.New("zone without an ip6 address")}
 			return
 		}
+		hp.host += "%" + zone
+	}
+
+	return
+}
+
+// ParseMultiaddr returns the host:port address of m and the code of its
+// transport protocol, either ma.P_UDP or ma.P_TCP. Hostnames of /dns, /dns4
+// and /dns6 addresses are left unresolved.
+func ParseMultiaddr(m ma.Multiaddr) (laddr string, code int, err error) {
+	hp, err := splitMultiaddr(m)
+	if err != nil {
+		return
+	}
+
+	return net.JoinHostPort(hp.host, hp.port), hp.code, nil
+}
+
+// Network returns the name of the network of m as used by the net package,
+// such as "udp4" or "tcp6". Addresses that may resolve to both IPv4 and
+// IPv6 give "udp" or "tcp".
+func Network(m ma.Multiaddr) (string, error) {
+	hp, err := splitMultiaddr(m)
+	if err != nil {
+		return "", err
+	}
+
+	network := "tcp"
+	if hp.code == ma.P_UDP {
+		network = "udp"
+	}
+
+	switch hp.hostCode {
+	case ma.P_IP4, madns.Dns4Protocol.Code:
+		network += "4"
+	case ma.P_IP6, madns.Dns6Protocol.Code:
+		network += "6"
+	}
+
+	return network, nil
+}
+
+// Hostname returns the host of m, which is either an IP address or the
+// unresolved name of a /dns, /dns4 or /dns6 address.
+func Hostname(m ma.Multiaddr) (string, error) {
+	hp, err := splitMultiaddr(m)
+	if err != nil {
+		return "", err
+	}
+
+	return hp.host, nil
+}
+
+// HasHostname reports whether the host of m is the name of a /dns, /dns4 or
+// /dns6 address rather than an IP address.
+func HasHostname(m ma.Multiaddr) bool {
+	hp, err := splitMultiaddr(m)
+	return err == nil && hp.hostCode != ma.P_IP4 && hp.hostCode != ma.P_IP6
+}
+
+// ResolveMultiaddr returns the host:port address to dial m and the code of
+// its transport protocol. Hostnames are looked up with r and the first
+// address of the family requested by m is used.
+func ResolveMultiaddr(ctx context.Context, r Resolver, m ma.Multiaddr) (raddr string, code int, err error) {
+	hp, err := splitMultiaddr(m)
+	if err != nil {
+		return
+	}
+
+	switch hp.hostCode {
+	case ma.P_IP4, ma.P_IP6:
+		return net.JoinHostPort(hp.host, hp.port), hp.code, nil
+	}
+
+	if r == nil {
+		r = net.DefaultResolver
+	}
+
+	addrs, err := r.LookupIPAddr(ctx, hp.host)
+	if err != nil {
+		return
+	}
+
+	for _, addr := range addrs {
+		is4 := addr.IP.To4() != nil
+		if (hp.hostCode == madns.Dns4Protocol.Code && !is4) || (hp.hostCode == madns.Dns6Protocol.Code && is4) {
+			continue
+		}
+
+		host := addr.IP.String()
+		if addr.Zone != "" {
+			host += "%" + addr.Zone
+		}
+
+		return net.JoinHostPort(host, hp.port), hp.code, nil
 	}
 
-	laddr = addr + ":" + port
+	err = fmt.Errorf("no address found for `%s`", m)
 	return
 }
+
+// WithTransport returns m with its transport replaced by the protocol code,
+// either ma.P_UDP or ma.P_TCP, keeping the same host and port.
+func WithTransport(m ma.Multiaddr, code int) (ma.Multiaddr, error) {
+	if code != ma.P_UDP && code != ma.P_TCP {
+		return nil, fmt.Errorf("not supported transport `%d`", code)
+	}
+
+	parts := ma.Split(m)
+	for i, part := range parts {
+		p := part.Protocols()[0]
+		if p.Code != ma.P_UDP && p.Code != ma.P_TCP {
+			continue
+		}
+
+		port, err := part.ValueForProtocol(p.Code)
+		if err != nil {
+			return nil, err
+		}
+
+		c, err := ma.NewComponent(ma.ProtocolWithCode(code).Name, port)
+		if err != nil {
+			return nil, err
+		}
+
+		parts[i] = c
+		return ma.Join(parts...), nil
+	}
+
+	return nil, &MultiaddrError{Addr: m.String(), Err: errMissingTransport}
+}
@@ -2,22 +2,48 @@ package opts
 
 import (
 	"crypto/tls"
+	"time"
 
+	quiccache "github.com/gfanton/grpc-quic/cache"
+	quicmetrics "github.com/gfanton/grpc-quic/metrics"
+	quicresolver "github.com/gfanton/grpc-quic/resolver"
+	quic "github.com/lucas-clemente/quic-go"
 	"google.golang.org/grpc"
 )
 
 type ClientConfig struct {
-	GrpcDialOptions []grpc.DialOption
+	GrpcDialOptions   []grpc.DialOption
+	StreamInterceptor grpc.StreamClientInterceptor
 
-	TLSConf  *tls.Config
-	Insecure bool
+	TLSConf       *tls.Config
+	TLSSource     TLSConfigSource
+	Insecure      bool
+	NativeStreams bool
+	QuicConf      *quic.Config
+
+	HappyEyeballs      bool
+	HappyEyeballsDelay time.Duration
+
+	QuicCache   quiccache.Cache
+	QuicUpgrade bool
+
+	DNSResolver        quicresolver.DNSResolver
+	DNSRefreshInterval time.Duration
+
+	Metrics quicmetrics.Metrics
 }
 
+// DefaultHappyEyeballsDelay is the head start given to QUIC over TCP by
+// WithHappyEyeballs.
+const DefaultHappyEyeballsDelay = 300 * time.Millisecond
+
 // DialOption configures how we set up the connection.
 type DialOption func(o *ClientConfig) error
 
 func NewClientConfig() *ClientConfig {
-	return &ClientConfig{}
+	return &ClientConfig{
+		QuicConf: DefaultQuicConfig(),
+	}
 }
 
 func (c *ClientConfig) Apply(opts ...DialOption) error {
@@ -31,8 +57,9 @@ func (c *ClientConfig) Apply(opts ...DialOption) error {
 }
 
 // WithInsecure returns a DialOption which disables transport security for this
-// ClientConn. Note that transport security is required unless WithInsecure is
-// set.
+// ClientConn: TCP connections are in plaintext, and since QUIC requires TLS,
+// the certificates of QUIC servers are not verified. Note that transport
+// security is required unless WithInsecure is set.
 func WithInsecure() DialOption {
 	return func(o *ClientConfig) error {
 		o.Insecure = true
@@ -40,9 +67,172 @@ func WithInsecure() DialOption {
 	}
 }
 
+// WithTLSConfig returns a DialOption which sets the TLS config of the
+// client. gQUIC does not carry client certificates, its Certificates are
+// only presented over QUIC with net.VersionTLS, see WithQuicVersions.
 func WithTLSConfig(tlsConf *tls.Config) DialOption {
 	return func(o *ClientConfig) error {
 		o.TLSConf = tlsConf
 		return nil
 	}
 }
+
+// TLSConfigSource provides TLS configs whose certificates may change over
+// time, such as transports.FileSource.
+type TLSConfigSource interface {
+	// ServerTLSConfig returns a config getting the current certificates on
+	// every handshake.
+	ServerTLSConfig() *tls.Config
+
+	// ClientTLSConfig returns a config for a new connection.
+	ClientTLSConfig() *tls.Config
+}
+
+// WithTLSSource returns a DialOption that takes the TLS config of every new
+// connection from src, so certificates and CAs can be rotated without
+// redialing. It overrides WithTLSConfig.
+func WithTLSSource(src TLSConfigSource) DialOption {
+	return func(o *ClientConfig) error {
+		o.TLSSource = src
+		return nil
+	}
+}
+
+// WithNativeStreams returns a DialOption which maps every gRPC call on its own
+// QUIC stream instead of tunnelling HTTP/2 over a single stream. The server
+// must be set up with NativeStreams. It has no effect on TCP connections.
+func WithNativeStreams() DialOption {
+	return func(o *ClientConfig) error {
+		o.NativeStreams = true
+		return nil
+	}
+}
+
+// WithDNSResolver returns a DialOption which sets the resolver used to look up
+// /dnsaddr targets and the hostnames of /dns, /dns4 and /dns6 addresses.
+func WithDNSResolver(r quicresolver.DNSResolver) DialOption {
+	return func(o *ClientConfig) error {
+		o.DNSResolver = r
+		return nil
+	}
+}
+
+// WithDNSRefreshInterval returns a DialOption which sets the interval between
+// two lookups of a /dnsaddr target. The default is 5 minutes.
+func WithDNSRefreshInterval(d time.Duration) DialOption {
+	return func(o *ClientConfig) error {
+		o.DNSRefreshInterval = d
+		return nil
+	}
+}
+
+// WithHappyEyeballs returns a DialOption which races QUIC against TCP when
+// dialing an udp multiaddr: after QUIC had a head start of delay, a TCP+TLS
+// connection is attempted to the same host and port, and the first one to
+// complete its handshake is used. This avoids waiting for the QUIC handshake
+// timeout on networks dropping UDP. If delay is zero, DefaultHappyEyeballsDelay
+// is used.
+func WithHappyEyeballs(delay time.Duration) DialOption {
+	return func(o *ClientConfig) error {
+		o.HappyEyeballs = true
+		o.HappyEyeballsDelay = delay
+		return nil
+	}
+}
+
+// WithQuicCache returns a DialOption which records in c whether QUIC worked
+// for each dialed host and network. When QUIC was blocked the last time, udp
+// multiaddrs are dialed over TCP+TLS first, and over QUIC only if TCP fails
+// or, with WithHappyEyeballs, once TCP had its head start.
+func WithQuicCache(c quiccache.Cache) DialOption {
+	return func(o *ClientConfig) error {
+		o.QuicCache = c
+		return nil
+	}
+}
+
+// WithQuicUpgrade returns a DialOption which asks the servers for the QUIC
+// endpoints they advertise, see AdvertiseQuic, and adds them to the addresses
+// of the ClientConn. Use it with a balancer preferring UDP, such as
+// quicbalancer, to move onto QUIC once the endpoints are known. Like with
+// Alt-Svc, the certificates of the advertised endpoints must be valid for the
+// host of the server which advertised them.
+func WithQuicUpgrade() DialOption {
+	return func(o *ClientConfig) error {
+		o.QuicUpgrade = true
+		return nil
+	}
+}
+
+// WithMetrics returns a DialOption which reports the measures of every
+// connection dialed by this ClientConn to m, labelled by dialed multiaddr and
+// protocol, such as a quicmetrics.Collector.
+func WithMetrics(m quicmetrics.Metrics) DialOption {
+	return func(o *ClientConfig) error {
+		o.Metrics = m
+		return nil
+	}
+}
+
+// WithQuicConfig returns a DialOption which sets the configuration of the
+// QUIC sessions dialed by this ClientConn. The config is copied, options
+// tuning QUIC must be given after this one.
+func WithQuicConfig(c *quic.Config) DialOption {
+	return func(o *ClientConfig) error {
+		o.QuicConf = copyQuicConfig(c)
+		return nil
+	}
+}
+
+// WithHandshakeTimeout returns a DialOption which sets the maximum duration
+// of the QUIC handshake. The default is 10 seconds.
+func WithHandshakeTimeout(d time.Duration) DialOption {
+	return func(o *ClientConfig) error {
+		return setHandshakeTimeout(o.QuicConf, d)
+	}
+}
+
+// WithIdleTimeout returns a DialOption which sets the maximum duration a QUIC
+// session may stay without any incoming network activity. The default is 30
+// seconds.
+func WithIdleTimeout(d time.Duration) DialOption {
+	return func(o *ClientConfig) error {
+		return setIdleTimeout(o.QuicConf, d)
+	}
+}
+
+// WithFlowControlWindows returns a DialOption which sets the maximum stream
+// and connection flow control windows for receiving data, in bytes. Zero
+// keeps the default of 6 MB per stream and 15 MB per connection.
+func WithFlowControlWindows(stream, conn uint64) DialOption {
+	return func(o *ClientConfig) error {
+		return setFlowControlWindows(o.QuicConf, stream, conn)
+	}
+}
+
+// WithMaxIncomingStreams returns a DialOption which sets the maximum number of
+// concurrent streams the server may open. The default is 100, a negative
+// value forbids any.
+func WithMaxIncomingStreams(n int) DialOption {
+	return func(o *ClientConfig) error {
+		return setMaxIncomingStreams(o.QuicConf, n)
+	}
+}
+
+// WithConnectionIDLength returns a DialOption which sets the length of the
+// connection ID in bytes, either 0 or between 4 and 18. It only applies to
+// IETF QUIC.
+func WithConnectionIDLength(n int) DialOption {
+	return func(o *ClientConfig) error {
+		return setConnectionIDLength(o.QuicConf, n)
+	}
+}
+
+// WithQuicVersions returns a DialOption which sets the QUIC versions that can
+// be negotiated, by order of preference. The gQUIC versions are used by
+// default, net.VersionTLS must be listed to present client certificates.
+func WithQuicVersions(versions ...quic.VersionNumber) DialOption {
+	return func(o *ClientConfig) error {
+		return setQuicVersions(o.QuicConf, versions)
+	}
+}
@@ -270,9 +270,14 @@ func WithUnaryInterceptor(f grpc.UnaryClientInterceptor) DialOption {
 }
 
 // WithStreamInterceptor returns a DialOption that specifies the interceptor for
-// streaming RPCs.
+// streaming RPCs. It is chained after quicbalancer.StreamClientInterceptor,
+// and is the only way to set one: grpc.WithStreamInterceptor would replace
+// the interceptor of the balancer, Dial rejects it.
 func WithStreamInterceptor(f grpc.StreamClientInterceptor) DialOption {
-	return withGrpcDialOptions(grpc.WithStreamInterceptor(f))
+	return func(o *ClientConfig) error {
+		o.StreamInterceptor = f
+		return nil
+	}
 }
 
 // WithAuthority returns a DialOption that specifies the value to be used as the
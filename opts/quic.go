@@ -0,0 +1,84 @@
+package opts
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	quic "github.com/lucas-clemente/quic-go"
+)
+
+// DefaultQuicConfig returns the QUIC configuration used when none is given.
+func DefaultQuicConfig() *quic.Config {
+	return &quic.Config{
+		KeepAlive: true,
+	}
+}
+
+// copyQuicConfig returns a copy of c, so options never modify a config owned
+// by the caller.
+func copyQuicConfig(c *quic.Config) *quic.Config {
+	if c == nil {
+		return DefaultQuicConfig()
+	}
+
+	conf := *c
+	conf.Versions = append([]quic.VersionNumber(nil), c.Versions...)
+
+	return &conf
+}
+
+func setHandshakeTimeout(c *quic.Config, d time.Duration) error {
+	if d < 0 {
+		return fmt.Errorf("invalid handshake timeout `%s`", d)
+	}
+
+	c.HandshakeTimeout = d
+	return nil
+}
+
+func setIdleTimeout(c *quic.Config, d time.Duration) error {
+	if d < 0 {
+		return fmt.Errorf("invalid idle timeout `%s`", d)
+	}
+
+	c.IdleTimeout = d
+	return nil
+}
+
+func setFlowControlWindows(c *quic.Config, stream, conn uint64) error {
+	if stream > 0 && conn > 0 && stream > conn {
+		return fmt.Errorf("stream flow control window `%d` larger than the connection one `%d`", stream, conn)
+	}
+
+	c.MaxReceiveStreamFlowControlWindow = stream
+	c.MaxReceiveConnectionFlowControlWindow = conn
+	return nil
+}
+
+func setMaxIncomingStreams(c *quic.Config, n int) error {
+	if n > math.MaxUint16 {
+		return fmt.Errorf("invalid max incoming streams `%d`", n)
+	}
+
+	c.MaxIncomingStreams = n
+	return nil
+}
+
+func setConnectionIDLength(c *quic.Config, n int) error {
+	if n != 0 && (n < 4 || n > 18) {
+		return fmt.Errorf("invalid connection ID length `%d`, must be 0 or between 4 and 18", n)
+	}
+
+	c.ConnectionIDLength = n
+	return nil
+}
+
+func setQuicVersions(c *quic.Config, versions []quic.VersionNumber) error {
+	if len(versions) == 0 {
+		return fmt.Errorf("no QUIC version")
+	}
+
+	c.Versions = append([]quic.VersionNumber(nil), versions...)
+	return nil
+}
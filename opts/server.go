@@ -2,22 +2,42 @@ package opts
 
 import (
 	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
 
+	quicmetrics "github.com/gfanton/grpc-quic/metrics"
+	qnet "github.com/gfanton/grpc-quic/net"
+	quic "github.com/lucas-clemente/quic-go"
+	ma "github.com/multiformats/go-multiaddr"
 	"google.golang.org/grpc"
 )
 
 type ServerConfig struct {
 	GrpcServerOptions []grpc.ServerOption
 
-	TLSConf  *tls.Config
-	Insecure bool
+	TLSConf       *tls.Config
+	Insecure      bool
+	NativeStreams bool
+	QuicConf      *quic.Config
+
+	AcceptTimeout      time.Duration
+	AcceptBacklog      int
+	AcceptErrorHandler func(remote net.Addr, err error)
+	Admission          qnet.AdmissionFunc
+	Registry           *qnet.Registry
+	Metrics            quicmetrics.Metrics
+
+	AdvertiseAddrs []ma.Multiaddr
 }
 
 // ServerOption configures how we set up the connection.
 type ServerOption func(o *ServerConfig) error
 
 func NewServerConfig() *ServerConfig {
-	return &ServerConfig{}
+	return &ServerConfig{
+		QuicConf: DefaultQuicConfig(),
+	}
 }
 
 func (c *ServerConfig) Apply(opts ...ServerOption) error {
@@ -30,9 +50,10 @@ func (c *ServerConfig) Apply(opts ...ServerOption) error {
 	return nil
 }
 
-// WithInsecure returns a ServerOption which disables transport security for this
-// ServerConn. Note that transport security is required unless WithInsecure is
-// set.
+// Insecure returns a ServerOption which disables transport security for this
+// server: TCP listeners serve plaintext HTTP/2, and since QUIC requires TLS,
+// QUIC listeners use an ephemeral self-signed certificate. TLSConfig is
+// ignored. Note that transport security is required unless Insecure is set.
 func Insecure() ServerOption {
 	return func(o *ServerConfig) error {
 		o.Insecure = true
@@ -40,9 +61,194 @@ func Insecure() ServerOption {
 	}
 }
 
+// TLSConfig returns a ServerOption which sets the TLS config of the server.
+// gQUIC does not carry client certificates, so a config whose ClientAuth is
+// not NoClientCert requires net.VersionTLS among the QuicVersions to listen
+// on udp multiaddrs. QUIC listeners verify the client certificates once the
+// handshake completed, against ClientCAs, and close the sessions failing
+// with net.AuthFailureCode. Note that net.VersionTLS only takes the
+// Certificates of the config, and presents the one whose DNS names include
+// the server name sent by the client.
 func TLSConfig(tlsConf *tls.Config) ServerOption {
 	return func(o *ServerConfig) error {
 		o.TLSConf = tlsConf
 		return nil
 	}
 }
+
+// TLSSource returns a ServerOption that takes the certificates of every
+// handshake from src, so they can be rotated without restarting the server.
+// Established connections are left as is. It overrides TLSConfig.
+func TLSSource(src TLSConfigSource) ServerOption {
+	return func(o *ServerConfig) error {
+		o.TLSConf = src.ServerTLSConfig()
+		return nil
+	}
+}
+
+// NativeStreams returns a ServerOption which serves every QUIC stream as a
+// single gRPC call, see WithNativeStreams. It has no effect on TCP listeners.
+//
+// The calls are handed to grpc.Server.ServeHTTP, which bypasses the HTTP/2
+// transport of gRPC, so the following options do not apply to QUIC
+// listeners: the transport credentials, MaxConcurrentStreams,
+// KeepaliveParams, KeepaliveEnforcementPolicy, InTapHandle,
+// ConnectionTimeout, the window and buffer sizes, and MaxHeaderListSize,
+// header lists being capped at 16KB. The StatsHandler only sees the calls,
+// not the connections. Use TLSConfig, MaxIncomingStreams, IdleTimeout,
+// HandshakeTimeout and FlowControlWindows to tune them instead.
+func NativeStreams() ServerOption {
+	return func(o *ServerConfig) error {
+		o.NativeStreams = true
+		return nil
+	}
+}
+
+// AcceptTimeout returns a ServerOption that sets the time a new QUIC session
+// has to open its first stream before being closed. If this is not set, the
+// default is 10 seconds.
+func AcceptTimeout(d time.Duration) ServerOption {
+	return func(o *ServerConfig) error {
+		o.AcceptTimeout = d
+		return nil
+	}
+}
+
+// AcceptBacklog returns a ServerOption that sets the number of ready QUIC
+// connections queued until the server accepts them.
+func AcceptBacklog(n int) ServerOption {
+	return func(o *ServerConfig) error {
+		o.AcceptBacklog = n
+		return nil
+	}
+}
+
+// AcceptErrorHandler returns a ServerOption that sets a handler called with
+// every QUIC session dropped before being handed to the server, either
+// because it timed out or failed to open its first stream.
+func AcceptErrorHandler(h func(remote net.Addr, err error)) ServerOption {
+	return func(o *ServerConfig) error {
+		o.AcceptErrorHandler = h
+		return nil
+	}
+}
+
+// Admission returns a ServerOption that sets a hook called with the remote
+// address and TLS state of every new QUIC session, before it is handed to the
+// server. Returning an error rejects the session, which is closed with the
+// code of a net.RejectError, or net.AdmissionRejectedCode otherwise. Rejected
+// sessions are also reported to the AcceptErrorHandler. It has no effect on
+// TCP listeners.
+func Admission(f qnet.AdmissionFunc) ServerOption {
+	return func(o *ServerConfig) error {
+		o.Admission = f
+		return nil
+	}
+}
+
+// ConnectionRegistry returns a ServerOption that keeps the QUIC sessions
+// served by the server in r, to be inspected and closed through it, for
+// example with the quicadmin service. It has no effect on TCP listeners.
+func ConnectionRegistry(r *qnet.Registry) ServerOption {
+	return func(o *ServerConfig) error {
+		o.Registry = r
+		return nil
+	}
+}
+
+// Metrics returns a ServerOption that reports the measures of every listener
+// and of the connections it accepts to m, labelled by listen address and
+// protocol, such as a quicmetrics.Collector.
+func Metrics(m quicmetrics.Metrics) ServerOption {
+	return func(o *ServerConfig) error {
+		o.Metrics = m
+		return nil
+	}
+}
+
+// AdvertiseQuic returns a ServerOption that advertises the udp multiaddrs
+// addrs to clients, so those dialed with WithQuicUpgrade over TCP move onto
+// QUIC. The addresses must be reachable by the clients.
+func AdvertiseQuic(addrs ...string) ServerOption {
+	return func(o *ServerConfig) error {
+		for _, addr := range addrs {
+			m, err := ma.NewMultiaddr(addr)
+			if err != nil {
+				return err
+			}
+
+			if _, protocol, err := qnet.ParseMultiaddr(m); err != nil {
+				return err
+			} else if protocol != ma.P_UDP {
+				return fmt.Errorf("`%s` is not an udp multiaddr", m)
+			}
+
+			o.AdvertiseAddrs = append(o.AdvertiseAddrs, m)
+		}
+
+		return nil
+	}
+}
+
+// QuicConfig returns a ServerOption that sets the configuration of the QUIC
+// listener. The config is copied, options tuning QUIC must be given after
+// this one.
+func QuicConfig(c *quic.Config) ServerOption {
+	return func(o *ServerConfig) error {
+		o.QuicConf = copyQuicConfig(c)
+		return nil
+	}
+}
+
+// HandshakeTimeout returns a ServerOption that sets the maximum duration of
+// the QUIC handshake. The default is 10 seconds.
+func HandshakeTimeout(d time.Duration) ServerOption {
+	return func(o *ServerConfig) error {
+		return setHandshakeTimeout(o.QuicConf, d)
+	}
+}
+
+// IdleTimeout returns a ServerOption that sets the maximum duration a QUIC
+// session may stay without any incoming network activity. The default is 30
+// seconds.
+func IdleTimeout(d time.Duration) ServerOption {
+	return func(o *ServerConfig) error {
+		return setIdleTimeout(o.QuicConf, d)
+	}
+}
+
+// FlowControlWindows returns a ServerOption that sets the maximum stream and
+// connection flow control windows for receiving data, in bytes. Zero keeps
+// the default of 1 MB per stream and 1.5 MB per connection.
+func FlowControlWindows(stream, conn uint64) ServerOption {
+	return func(o *ServerConfig) error {
+		return setFlowControlWindows(o.QuicConf, stream, conn)
+	}
+}
+
+// MaxIncomingStreams returns a ServerOption that sets the maximum number of
+// concurrent streams a client may open, which bounds the concurrent calls
+// of a client using native streams. The default is 100.
+func MaxIncomingStreams(n int) ServerOption {
+	return func(o *ServerConfig) error {
+		return setMaxIncomingStreams(o.QuicConf, n)
+	}
+}
+
+// ConnectionIDLength returns a ServerOption that sets the length of the
+// connection ID in bytes, either 0 or between 4 and 18. It only applies to
+// IETF QUIC.
+func ConnectionIDLength(n int) ServerOption {
+	return func(o *ServerConfig) error {
+		return setConnectionIDLength(o.QuicConf, n)
+	}
+}
+
+// QuicVersions returns a ServerOption that sets the QUIC versions that can be
+// negotiated. The gQUIC versions are used by default, net.VersionTLS must be
+// listed to accept client certificates.
+func QuicVersions(versions ...quic.VersionNumber) ServerOption {
+	return func(o *ServerConfig) error {
+		return setQuicVersions(o.QuicConf, versions)
+	}
+}
@@ -0,0 +1,279 @@
+package quicresolver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	qnet "github.com/gfanton/grpc-quic/net"
+	ma "github.com/multiformats/go-multiaddr"
+	madns "github.com/multiformats/go-multiaddr-dns"
+	"google.golang.org/grpc/grpclog"
+	"google.golang.org/grpc/resolver"
+)
+
+// DNSAddrScheme is the scheme of the /dnsaddr resolver.
+const DNSAddrScheme = "dnsaddr"
+
+// DefaultRefreshInterval is the interval between two lookups of a /dnsaddr
+// name.
+const DefaultRefreshInterval = 5 * time.Minute
+
+// minResolveInterval rate limits the lookups requested by gRPC through
+// ResolveNow.
+const minResolveInterval = 5 * time.Second
+
+// maxDNSAddrDepth bounds the recursion of /dnsaddr records pointing to other
+// /dnsaddr names.
+const maxDNSAddrDepth = 8
+
+// DNSResolver looks up the records needed to resolve multiaddrs.
+// net.DefaultResolver is used when none is given, madns.MockBackend can be
+// used as a local stand-in.
+type DNSResolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// DNSAddrConfig configures the resolution of a /dnsaddr target.
+type DNSAddrConfig struct {
+	// Resolver is used for TXT lookups. If nil, net.DefaultResolver is used.
+	Resolver DNSResolver
+
+	// RefreshInterval is the interval between two lookups. If zero,
+	// DefaultRefreshInterval is used.
+	RefreshInterval time.Duration
+}
+
+var dnsaddrBuilder = &dnsaddrResolverBuilder{
+	configs: make(map[string]*DNSAddrConfig),
+}
+
+func init() {
+	resolver.Register(dnsaddrBuilder)
+}
+
+// DNSAddrTarget returns the gRPC target resolving the /dnsaddr multiaddr m
+// with cfg. The target must be dialed once, it is released when its resolver
+// is built.
+func DNSAddrTarget(m ma.Multiaddr, cfg *DNSAddrConfig) string {
+	var id string
+	if cfg != nil {
+		id = dnsaddrBuilder.register(cfg)
+	}
+
+	return DNSAddrScheme + "://" + id + m.String()
+}
+
+// ReleaseDNSAddrTarget releases a target returned by DNSAddrTarget that was
+// never dialed.
+func ReleaseDNSAddrTarget(target string) {
+	rest := strings.TrimPrefix(target, DNSAddrScheme+"://")
+	if i := strings.IndexByte(rest, '/'); i > 0 {
+		dnsaddrBuilder.take(rest[:i])
+	}
+}
+
+type dnsaddrResolverBuilder struct {
+	mu      sync.Mutex
+	configs map[string]*DNSAddrConfig
+	nextID  uint64
+}
+
+func (b *dnsaddrResolverBuilder) register(cfg *DNSAddrConfig) string {
+	id := strconv.FormatUint(atomic.AddUint64(&b.nextID, 1), 10)
+
+	b.mu.Lock()
+	b.configs[id] = cfg
+	b.mu.Unlock()
+
+	return id
+}
+
+func (b *dnsaddrResolverBuilder) take(id string) *DNSAddrConfig {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	cfg := b.configs[id]
+	delete(b.configs, id)
+	return cfg
+}
+
+func (b *dnsaddrResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOption) (resolver.Resolver, error) {
+	cfg := b.take(target.Authority)
+	if cfg == nil {
+		if target.Authority != "" {
+			return nil, fmt.Errorf("unknown dnsaddr target `%s`", target.Authority)
+		}
+
+		cfg = &DNSAddrConfig{}
+	}
+
+	m, err := ma.NewMultiaddr("/" + target.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isDNSAddr(m) {
+		return nil, fmt.Errorf("`%s` is not a dnsaddr multiaddr", m)
+	}
+
+	var backend DNSResolver = net.DefaultResolver
+	if cfg.Resolver != nil {
+		backend = cfg.Resolver
+	}
+
+	interval := cfg.RefreshInterval
+	if interval <= 0 {
+		interval = DefaultRefreshInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &dnsaddrResolver{
+		m:        m,
+		r:        &madns.Resolver{Backend: backend},
+		cc:       cc,
+		interval: interval,
+		rn:       make(chan struct{}, 1),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	r.wg.Add(1)
+	go r.watch()
+	return r, nil
+}
+
+func (b *dnsaddrResolverBuilder) Scheme() string {
+	return DNSAddrScheme
+}
+
+func isDNSAddr(m ma.Multiaddr) bool {
+	protos := m.Protocols()
+	return len(protos) > 0 && protos[0].Code == madns.DnsaddrProtocol.Code
+}
+
+type dnsaddrResolver struct {
+	m        ma.Multiaddr
+	r        *madns.Resolver
+	cc       resolver.ClientConn
+	interval time.Duration
+
+	rn     chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func (r *dnsaddrResolver) watch() {
+	defer r.wg.Done()
+
+	for {
+		last := time.Now()
+		addrs, err := r.lookup()
+		if err != nil {
+			grpclog.Warningf("quic: failed to resolve `%s`: %v", r.m, err)
+		} else {
+			r.cc.NewAddress(addrs)
+		}
+
+		timer := time.NewTimer(r.interval)
+		select {
+		case <-r.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		case <-r.rn:
+			timer.Stop()
+
+			if wait := minResolveInterval - time.Since(last); wait > 0 {
+				select {
+				case <-r.ctx.Done():
+					return
+				case <-time.After(wait):
+				}
+			}
+		}
+	}
+}
+
+// lookup resolves r.m into the udp and tcp multiaddrs it points to.
+// Hostnames of /dns, /dns4 and /dns6 records are resolved at dial time. The
+// /certhash pins of the records are dropped: TXT records are not
+// authenticated, whoever spoofs them must not choose the certificate the
+// dialer trusts.
+func (r *dnsaddrResolver) lookup() ([]resolver.Address, error) {
+	ctx, cancel := context.WithTimeout(r.ctx, 30*time.Second)
+	defer cancel()
+
+	var addrs []resolver.Address
+	seen := make(map[string]bool)
+
+	var resolve func(m ma.Multiaddr, depth int) error
+	resolve = func(m ma.Multiaddr, depth int) error {
+		if depth > maxDNSAddrDepth {
+			return fmt.Errorf("too many nested dnsaddr records in `%s`", r.m)
+		}
+
+		ms, err := r.r.Resolve(ctx, m)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range ms {
+			if isDNSAddr(m) {
+				if err := resolve(m, depth+1); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if _, _, err := qnet.ParseMultiaddr(m); err != nil {
+				grpclog.Infof("quic: ignoring `%s` from `%s`: %v", m, r.m, err)
+				continue
+			}
+
+			if len(qnet.CertHashes(m)) > 0 {
+				grpclog.Infof("quic: ignoring the certhash of `%s` from `%s`", m, r.m)
+				m = qnet.WithoutCertHash(m)
+			}
+
+			if addr := m.String(); !seen[addr] {
+				seen[addr] = true
+				addrs = append(addrs, resolver.Address{Addr: addr})
+			}
+		}
+
+		return nil
+	}
+
+	if err := resolve(r.m, 0); err != nil {
+		return nil, err
+	}
+
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no udp or tcp address found for `%s`", r.m)
+	}
+
+	return addrs, nil
+}
+
+// ResolveNow will be called by gRPC to try to resolve the target name
+// again.
+func (r *dnsaddrResolver) ResolveNow(opts resolver.ResolveNowOption) {
+	select {
+	case r.rn <- struct{}{}:
+	default:
+	}
+}
+
+// Close closes the resolver.
+func (r *dnsaddrResolver) Close() {
+	r.cancel()
+	r.wg.Wait()
+}
@@ -0,0 +1,83 @@
+package quicresolver
+
+import (
+	"fmt"
+	"strings"
+
+	qnet "github.com/gfanton/grpc-quic/net"
+	ma "github.com/multiformats/go-multiaddr"
+	"google.golang.org/grpc/resolver"
+)
+
+// MultiaddrScheme is the scheme of the multiaddr resolver. Its endpoint is a
+// comma separated list of multiaddrs, such as
+// `multiaddr:///ip4/10.0.0.1/udp/443,/ip4/10.0.0.1/tcp/443`.
+const MultiaddrScheme = "multiaddr"
+
+func init() {
+	resolver.Register(&multiaddrResolverBuilder{})
+}
+
+// MultiaddrTarget returns the gRPC target resolving to addrs.
+func MultiaddrTarget(addrs ...ma.Multiaddr) string {
+	list := make([]string, len(addrs))
+	for i, m := range addrs {
+		list[i] = m.String()
+	}
+
+	return MultiaddrScheme + "://" + strings.Join(list, ",")
+}
+
+// ParseAddresses parses a comma separated list of udp and tcp multiaddrs.
+func ParseAddresses(list string) ([]resolver.Address, error) {
+	var addrs []resolver.Address
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+
+		m, err := ma.NewMultiaddr(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid multiaddr `%s`: %v", s, err)
+		}
+
+		if _, _, err := qnet.ParseMultiaddr(m); err != nil {
+			return nil, fmt.Errorf("invalid multiaddr `%s`: %v", s, err)
+		}
+
+		addrs = append(addrs, resolver.Address{Addr: m.String()})
+	}
+
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no multiaddr in `%s`", list)
+	}
+
+	return addrs, nil
+}
+
+type multiaddrResolverBuilder struct{}
+
+func (*multiaddrResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOption) (resolver.Resolver, error) {
+	// gRPC strips the leading slash of the first multiaddr from the endpoint
+	addrs, err := ParseAddresses("/" + strings.TrimPrefix(target.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	cc.NewAddress(addrs)
+	return &multiaddrResolver{}, nil
+}
+
+func (*multiaddrResolverBuilder) Scheme() string {
+	return MultiaddrScheme
+}
+
+// multiaddrResolver resolves a static list of addresses.
+type multiaddrResolver struct{}
+
+// ResolveNow is a noop, the list of addresses never changes.
+func (*multiaddrResolver) ResolveNow(opts resolver.ResolveNowOption) {}
+
+// Close closes the resolver.
+func (*multiaddrResolver) Close() {}
@@ -0,0 +1,525 @@
+package grpcquic
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	quicaltsvc "github.com/gfanton/grpc-quic/altsvc"
+	quicmetrics "github.com/gfanton/grpc-quic/metrics"
+	qnet "github.com/gfanton/grpc-quic/net"
+	options "github.com/gfanton/grpc-quic/opts"
+	"github.com/gfanton/grpc-quic/transports"
+	quic "github.com/lucas-clemente/quic-go"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr-net"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/grpclog"
+)
+
+// ErrQuicClientAuth is returned when listening on an udp multiaddr with a TLS
+// config requesting client certificates while the QUIC config only allows
+// gQUIC versions, which do not carry them.
+var ErrQuicClientAuth = errors.New("client certificates over QUIC require net.VersionTLS in the QUIC versions")
+
+// Server is a gRPC server listening on udp and tcp multiaddrs. Services are
+// registered on the embedded grpc.Server.
+type Server struct {
+	*grpc.Server
+
+	cfg *options.ServerConfig
+
+	mu        sync.Mutex
+	listeners []net.Listener
+}
+
+// New creates a server configured by opts, including the gRPC server
+// options.
+func New(opts ...options.ServerOption) (*Server, error) {
+	cfg := options.NewServerConfig()
+	if err := cfg.Apply(opts...); err != nil {
+		return nil, err
+	}
+
+	creds := transports.NewCredentials(cfg.TLSConf)
+	if cfg.Insecure {
+		tlsConf, fingerprint, err := transports.EphemeralTLSConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		grpclog.Warningf("grpcquic: insecure mode, TCP is in plaintext and QUIC uses an ephemeral self-signed certificate (sha256 %s)", fingerprint)
+		cfg.TLSConf = tlsConf
+		creds = transports.NewInsecureCredentials()
+	}
+
+	grpcOpts := append([]grpc.ServerOption{grpc.Creds(creds)}, cfg.GrpcServerOptions...)
+	server := grpc.NewServer(grpcOpts...)
+	if len(cfg.AdvertiseAddrs) > 0 {
+		if err := quicaltsvc.Register(server, cfg.AdvertiseAddrs...); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Server{Server: server, cfg: cfg}, nil
+}
+
+// NewServer creates a gRPC server configured by opts and its listener on
+// the multiaddr laddr. Use New to serve several multiaddrs or to stop QUIC
+// listeners gracefully.
+func NewServer(laddr string, opts ...options.ServerOption) (*grpc.Server, net.Listener, error) {
+	s, err := New(opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	l, err := s.Listen(laddr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s.Server, l, nil
+}
+
+// Listen returns a listener on the multiaddr laddr, to be served with Serve.
+// It is closed when the server stops. Listening on an udp multiaddr fails
+// with ErrQuicClientAuth if the TLS config requests client certificates but
+// net.VersionTLS is not among the QUIC versions.
+func (s *Server) Listen(laddr string) (net.Listener, error) {
+	l, err := newListener(laddr, s.cfg, s.Server)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.listeners = append(s.listeners, l)
+	s.mu.Unlock()
+
+	return l, nil
+}
+
+// ListenAndServe listens on every multiaddr of laddrs, such as the udp and
+// tcp multiaddrs of the same port, and serves them until the server stops.
+// It returns the first error of a listener.
+func (s *Server) ListenAndServe(laddrs ...string) error {
+	if len(laddrs) == 0 {
+		return fmt.Errorf("no multiaddr to listen on")
+	}
+
+	listeners := make([]net.Listener, 0, len(laddrs))
+	for _, laddr := range laddrs {
+		l, err := s.Listen(laddr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+
+			return err
+		}
+
+		listeners = append(listeners, l)
+	}
+
+	errs := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func(l net.Listener) {
+			errs <- s.Serve(l)
+		}(l)
+	}
+
+	var err error
+	for range listeners {
+		if serr := <-errs; serr != nil && err == nil {
+			err = serr
+			// stop the other listeners so the server fails as a whole
+			for _, l := range listeners {
+				l.Close()
+			}
+		}
+	}
+
+	return err
+}
+
+// GracefulStop stops the server from accepting new connections and RPCs and
+// blocks until all the pending RPCs are finished, then closes the QUIC
+// listeners and their UDP sockets.
+func (s *Server) GracefulStop() {
+	s.Shutdown(context.Background())
+}
+
+// Shutdown stops the server gracefully like GracefulStop, but only waits for
+// the pending RPCs until ctx is done: gRPC sends GOAWAY over every
+// connection, and the QUIC sessions still open once ctx is done are closed
+// with net.ShutdownCode, the TCP connections are closed as by Stop.
+// It returns ctx.Err() if some RPCs were cut.
+func (s *Server) Shutdown(ctx context.Context) error {
+	listeners := s.takeListeners()
+
+	// gRPC closes its listeners as soon as it stops, the QUIC ones must
+	// keep their sessions until they are drained
+	var native, quicListeners []*quicListener
+	for _, l := range listeners {
+		ql, ok := l.(*quicListener)
+		if !ok {
+			continue
+		}
+
+		ql.setDraining(true)
+		if _, ok := ql.Listener.(*qnet.StreamsListener); ok {
+			native = append(native, ql)
+		} else {
+			quicListeners = append(quicListeners, ql)
+		}
+	}
+
+	// gRPC cannot drain calls served on native streams, so they are
+	// drained by their listener beforehand
+	err := shutdownListeners(ctx, native)
+
+	stopped := make(chan struct{})
+	go func() {
+		s.Server.GracefulStop()
+		close(stopped)
+	}()
+
+	if lerr := shutdownListeners(ctx, quicListeners); err == nil {
+		err = lerr
+	}
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.Server.Stop()
+		<-stopped
+		err = ctx.Err()
+	}
+
+	// close the listeners which were never served
+	for _, l := range listeners {
+		l.Close()
+	}
+
+	return err
+}
+
+// shutdownListeners shuts listeners down concurrently and returns the first
+// error.
+func shutdownListeners(ctx context.Context, listeners []*quicListener) error {
+	errs := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func(l *quicListener) {
+			errs <- l.shutdown(ctx)
+		}(l)
+	}
+
+	var err error
+	for range listeners {
+		if lerr := <-errs; lerr != nil && err == nil {
+			err = lerr
+		}
+	}
+
+	return err
+}
+
+// Stop closes all the connections and listeners of the server, including
+// the QUIC listeners and their UDP sockets.
+func (s *Server) Stop() {
+	s.Server.Stop()
+	for _, l := range s.takeListeners() {
+		l.Close()
+	}
+}
+
+// certificate returns the current certificate of the server.
+func (s *Server) certificate() (*x509.Certificate, error) {
+	var tlsCert *tls.Certificate
+	if conf := s.cfg.TLSConf; conf != nil {
+		if len(conf.Certificates) > 0 {
+			tlsCert = &conf.Certificates[0]
+		} else if conf.GetCertificate != nil {
+			tlsCert, _ = conf.GetCertificate(&tls.ClientHelloInfo{})
+		}
+	}
+
+	if tlsCert == nil || len(tlsCert.Certificate) == 0 {
+		return nil, fmt.Errorf("no certificate to pin")
+	}
+
+	return x509.ParseCertificate(tlsCert.Certificate[0])
+}
+
+// PinnedAddrs returns the multiaddrs the server listens on, pinned to its
+// certificate with /certhash, so clients can verify a self-signed server.
+// Listeners on 0.0.0.0 or :: are listed with the addresses of the interfaces
+// of their IP version. In insecure mode, only the QUIC multiaddrs are
+// returned. With a TLS source, the addresses are pinned to the current
+// certificate.
+func (s *Server) PinnedAddrs() ([]ma.Multiaddr, error) {
+	cert, err := s.certificate()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	listeners := append([]net.Listener(nil), s.listeners...)
+	s.mu.Unlock()
+
+	var addrs []ma.Multiaddr
+	for _, l := range listeners {
+		if _, ok := l.(*quicListener); !ok && s.cfg.Insecure {
+			continue
+		}
+
+		m, err := manet.FromNetAddr(l.Addr())
+		if err != nil {
+			return nil, err
+		}
+
+		expanded, err := expandUnspecified(m)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, m := range expanded {
+			m, err = qnet.WithCertHash(m, cert)
+			if err != nil {
+				return nil, err
+			}
+
+			addrs = append(addrs, m)
+		}
+	}
+
+	return addrs, nil
+}
+
+// expandUnspecified returns m or, if its IP is unspecified and cannot be
+// dialed, m on every interface address of the same IP version. IPv6 link
+// local addresses are skipped since they need a zone.
+func expandUnspecified(m ma.Multiaddr) ([]ma.Multiaddr, error) {
+	if !manet.IsIPUnspecified(m) {
+		return []ma.Multiaddr{m}, nil
+	}
+
+	ip, rest := ma.SplitFirst(m)
+	ifaces, err := manet.InterfaceMultiaddrs()
+	if err != nil {
+		return nil, err
+	}
+
+	var addrs []ma.Multiaddr
+	for _, iface := range ifaces {
+		first, _ := ma.SplitFirst(iface)
+		if first == nil || first.Protocol().Code != ip.Protocol().Code || manet.IsIP6LinkLocal(iface) {
+			continue
+		}
+
+		addrs = append(addrs, first.Encapsulate(rest))
+	}
+
+	return addrs, nil
+}
+
+func (s *Server) takeListeners() []net.Listener {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	listeners := s.listeners
+	s.listeners = nil
+	return listeners
+}
+
+// quicListener closes the UDP socket of a QUIC listener along with it, since
+// quic-go leaves it open.
+type quicListener struct {
+	net.Listener
+
+	pconn     net.PacketConn
+	closeOnce sync.Once
+	closeErr  error
+
+	mu       sync.Mutex
+	draining bool
+
+	// released is closed once the listener is closed, by gRPC or by
+	// shutdown, so that a failed Accept reaches gRPC after it stopped
+	released    chan struct{}
+	releaseOnce sync.Once
+}
+
+// Accept waits for and returns the next connection to the listener. While
+// the listener is drained, errors are held until it is closed, otherwise
+// gRPC would report the end of Accept as a failure of Serve.
+func (l *quicListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		l.mu.Lock()
+		draining := l.draining
+		l.mu.Unlock()
+
+		if draining {
+			<-l.released
+		}
+	}
+
+	return conn, err
+}
+
+// Close closes the listener along with its UDP socket. While the listener is
+// drained by shutdown, which closes it afterwards, Close only stops
+// accepting sessions.
+func (l *quicListener) Close() error {
+	l.releaseOnce.Do(func() { close(l.released) })
+
+	l.mu.Lock()
+	draining := l.draining
+	l.mu.Unlock()
+
+	if draining {
+		return nil
+	}
+
+	l.closeOnce.Do(func() {
+		l.closeErr = l.Listener.Close()
+		if err := l.pconn.Close(); l.closeErr == nil {
+			l.closeErr = err
+		}
+	})
+
+	return l.closeErr
+}
+
+func newQuicListener(l net.Listener, pconn net.PacketConn) *quicListener {
+	return &quicListener{Listener: l, pconn: pconn, released: make(chan struct{})}
+}
+
+func (l *quicListener) setDraining(draining bool) {
+	l.mu.Lock()
+	l.draining = draining
+	l.mu.Unlock()
+}
+
+// shutdown drains the sessions of the listener until ctx is done, then
+// closes it.
+func (l *quicListener) shutdown(ctx context.Context) error {
+	var err error
+	switch ql := l.Listener.(type) {
+	case *qnet.Listener:
+		err = ql.Shutdown(ctx)
+	case *qnet.StreamsListener:
+		err = ql.Shutdown(ctx)
+	}
+
+	l.setDraining(false)
+	if cerr := l.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// allowsVersionTLS returns true if qconf allows the QUIC version handshaking
+// with TLS, quic-go only uses gQUIC versions by default.
+func allowsVersionTLS(qconf *quic.Config) bool {
+	if qconf == nil {
+		return false
+	}
+
+	for _, v := range qconf.Versions {
+		if v == qnet.VersionTLS {
+			return true
+		}
+	}
+
+	return false
+}
+
+// quicServerTLSConfig returns the TLS config of the QUIC listeners. Their
+// TLS handshakes can only request client certificates, which are verified
+// by the listener afterwards, see net.ListenerConfig.TLSConfig.
+func quicServerTLSConfig(tlsConf *tls.Config) *tls.Config {
+	if tlsConf == nil || tlsConf.ClientAuth == tls.NoClientCert {
+		return tlsConf
+	}
+
+	tlsConf = tlsConf.Clone()
+	tlsConf.ClientAuth = tls.RequireAnyClientCert
+	tlsConf.VerifyPeerCertificate = nil
+	return tlsConf
+}
+
+func newListener(laddr string, cfg *options.ServerConfig, h http.Handler) (net.Listener, error) {
+	m, err := ma.NewMultiaddr(laddr)
+	if err != nil {
+		return nil, err
+	}
+
+	laddr, protocol, err := qnet.ParseMultiaddr(m)
+	if err != nil {
+		return nil, err
+	}
+
+	network, err := qnet.Network(m)
+	if err != nil {
+		return nil, err
+	}
+
+	if protocol == ma.P_UDP {
+		if cfg.TLSConf != nil && cfg.TLSConf.ClientAuth != tls.NoClientCert && !allowsVersionTLS(cfg.QuicConf) {
+			return nil, fmt.Errorf("cannot listen on `%s`: %w", m, ErrQuicClientAuth)
+		}
+
+		pconn, err := newPacketConn(network, laddr)
+		if err != nil {
+			return nil, err
+		}
+
+		quicConf := cfg.QuicConf
+		var handshakes *qnet.HandshakeTimer
+		if cfg.Metrics != nil {
+			handshakes, quicConf = qnet.NewHandshakeTimer(quicConf)
+		}
+
+		ql, err := quic.Listen(pconn, quicServerTLSConfig(cfg.TLSConf), quicConf)
+		if err != nil {
+			pconn.Close()
+			return nil, err
+		}
+
+		lcfg := &qnet.ListenerConfig{
+			AcceptTimeout: cfg.AcceptTimeout,
+			AcceptBacklog: cfg.AcceptBacklog,
+			OnAcceptError: cfg.AcceptErrorHandler,
+			TLSConfig:     cfg.TLSConf,
+			Admission:     cfg.Admission,
+			Registry:      cfg.Registry,
+			Metrics:       cfg.Metrics,
+			Handshakes:    handshakes,
+		}
+
+		if cfg.NativeStreams {
+			return newQuicListener(qnet.NewStreamsListener(ql, h, lcfg), pconn), nil
+		}
+
+		l := qnet.NewListener(ql, lcfg)
+
+		return newQuicListener(l, pconn), nil
+	}
+
+	if protocol == ma.P_TCP {
+		l, err := net.Listen(network, laddr)
+		if err != nil {
+			return nil, err
+		}
+		return qnet.MeterListener(l, cfg.Metrics, quicmetrics.ProtocolTCP), nil
+	}
+
+	return nil, &qnet.UnsupportedProtocolError{Addr: m.String(), Protocol: ma.ProtocolWithCode(protocol).Name}
+}
@@ -0,0 +1,202 @@
+package test
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"net/url"
+	"testing"
+	"time"
+
+	qgrpc "github.com/gfanton/grpc-quic"
+	quicadmin "github.com/gfanton/grpc-quic/admin"
+	qnet "github.com/gfanton/grpc-quic/net"
+	"github.com/gfanton/grpc-quic/opts"
+	"github.com/gfanton/grpc-quic/proto/admin"
+	"github.com/gfanton/grpc-quic/proto/hello"
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAdmin(t *testing.T) {
+	var (
+		server            *qgrpc.Server
+		client, adminConn *grpc.ClientConn
+		conn              *admin.Connection
+	)
+
+	defer func() {
+		for _, cc := range []*grpc.ClientConn{client, adminConn} {
+			if cc != nil {
+				cc.Close()
+			}
+		}
+
+		if server != nil {
+			server.Stop()
+		}
+	}()
+
+	Convey("Setup server with a connection registry", t, func(c C) {
+		tlsConf, err := generateTLSConfig()
+		c.So(err, ShouldBeNil)
+
+		registry := qnet.NewRegistry()
+		server, err = qgrpc.New(opts.TLSConfig(tlsConf), opts.ConnectionRegistry(registry))
+		c.So(err, ShouldBeNil)
+
+		hello.RegisterGreeterServer(server.Server, &Hello{})
+		quicadmin.Register(server.Server, registry)
+
+		go server.ListenAndServe("/ip4/127.0.0.1/udp/5907", "/ip4/127.0.0.1/tcp/5907")
+	})
+
+	Convey("Test the QUIC sessions are listed", t, func(c C) {
+		tlsConf := &tls.Config{InsecureSkipVerify: true}
+
+		var err error
+		client, err = qgrpc.Dial("/ip4/127.0.0.1/udp/5907", opts.WithTLSConfig(tlsConf))
+		c.So(err, ShouldBeNil)
+
+		adminConn, err = qgrpc.Dial("/ip4/127.0.0.1/tcp/5907",
+			opts.WithTLSConfig(tlsConf), opts.WithBlock(), opts.WithTimeout(time.Second))
+		c.So(err, ShouldBeNil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		_, err = hello.NewGreeterClient(client).SayHello(ctx, &hello.HelloRequest{Name: "World"})
+		c.So(err, ShouldBeNil)
+
+		adminClient := admin.NewAdminClient(adminConn)
+		rep, err := adminClient.ListConnections(ctx, &admin.ListConnectionsRequest{})
+		c.So(err, ShouldBeNil)
+		c.So(rep.Connections, ShouldHaveLength, 1)
+
+		conn = rep.Connections[0]
+		c.So(conn.RemoteAddr, ShouldStartWith, "127.0.0.1:")
+		c.So(conn.Protocol, ShouldNotBeEmpty)
+		c.So(conn.NativeStreams, ShouldBeFalse)
+		c.So(conn.PeerCertSha256, ShouldBeEmpty)
+		c.So(conn.BytesRead, ShouldBeGreaterThan, 0)
+		c.So(conn.BytesWritten, ShouldBeGreaterThan, 0)
+		c.So(time.Since(time.Unix(0, conn.StartUnixNano)), ShouldBeLessThan, 5*time.Second)
+
+		got, err := adminClient.GetConnection(ctx, &admin.GetConnectionRequest{Id: conn.Id})
+		c.So(err, ShouldBeNil)
+		c.So(got.RemoteAddr, ShouldEqual, conn.RemoteAddr)
+
+		_, err = adminClient.GetConnection(ctx, &admin.GetConnectionRequest{Id: conn.Id + 42})
+		c.So(status.Code(err), ShouldEqual, codes.NotFound)
+	})
+
+	Convey("Test closing a session", t, func(c C) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		adminClient := admin.NewAdminClient(adminConn)
+		_, err := adminClient.CloseConnection(ctx, &admin.CloseConnectionRequest{Id: conn.Id, Reason: "misbehaving"})
+		c.So(err, ShouldBeNil)
+
+		_, err = adminClient.CloseConnection(ctx, &admin.CloseConnectionRequest{Id: conn.Id + 42})
+		c.So(status.Code(err), ShouldEqual, codes.NotFound)
+
+		for {
+			_, err = adminClient.GetConnection(ctx, &admin.GetConnectionRequest{Id: conn.Id})
+			if status.Code(err) != codes.OK {
+				break
+			}
+
+			time.Sleep(10 * time.Millisecond)
+		}
+		c.So(status.Code(err), ShouldEqual, codes.NotFound)
+	})
+}
+
+func TestAdminPeerCertificate(t *testing.T) {
+	var (
+		server *qgrpc.Server
+		client *grpc.ClientConn
+	)
+
+	defer func() {
+		if client != nil {
+			client.Close()
+		}
+
+		if server != nil {
+			server.Stop()
+		}
+	}()
+
+	ca, err := newTestCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientCert, err := ca.issue(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: "client"},
+		DNSNames:    []string{"client.example.com"},
+		URIs:        []*url.URL{{Scheme: "spiffe", Host: "example.com", Path: "/client"}},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Convey("Setup server verifying client certificates over QUIC", t, func(c C) {
+		serverCert, err := ca.issue(&x509.Certificate{DNSNames: []string{"localhost"}})
+		c.So(err, ShouldBeNil)
+
+		tlsConf := &tls.Config{
+			Certificates: []tls.Certificate{serverCert},
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientCAs:    ca.pool,
+		}
+
+		registry := qnet.NewRegistry()
+		server, err = qgrpc.New(
+			opts.TLSConfig(tlsConf),
+			opts.QuicVersions(qnet.VersionTLS),
+			opts.ConnectionRegistry(registry),
+		)
+		c.So(err, ShouldBeNil)
+
+		hello.RegisterGreeterServer(server.Server, &Hello{})
+		quicadmin.Register(server.Server, registry)
+
+		l, err := server.Listen("/ip4/127.0.0.1/udp/5920")
+		c.So(err, ShouldBeNil)
+
+		go server.Serve(l)
+	})
+
+	Convey("Test the certificate of the client is listed", t, func(c C) {
+		tlsConf := &tls.Config{ServerName: "localhost", RootCAs: ca.pool, Certificates: []tls.Certificate{clientCert}}
+
+		var err error
+		client, err = qgrpc.Dial("/ip4/127.0.0.1/udp/5920",
+			opts.WithTLSConfig(tlsConf), opts.WithQuicVersions(qnet.VersionTLS))
+		c.So(err, ShouldBeNil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		rep, err := admin.NewAdminClient(client).ListConnections(ctx, &admin.ListConnectionsRequest{})
+		c.So(err, ShouldBeNil)
+		c.So(rep.Connections, ShouldHaveLength, 1)
+
+		sum := sha256.Sum256(clientCert.Certificate[0])
+		conn := rep.Connections[0]
+		c.So(conn.PeerCertSha256, ShouldEqual, hex.EncodeToString(sum[:]))
+		c.So(conn.PeerSubject, ShouldEqual, "CN=client")
+		c.So(conn.PeerDnsNames, ShouldResemble, []string{"client.example.com"})
+		c.So(conn.PeerUris, ShouldResemble, []string{"spiffe://example.com/client"})
+		c.So(conn.PeerVerified, ShouldBeTrue)
+	})
+}
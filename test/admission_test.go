@@ -0,0 +1,134 @@
+package test
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	qgrpc "github.com/gfanton/grpc-quic"
+	qnet "github.com/gfanton/grpc-quic/net"
+	"github.com/gfanton/grpc-quic/opts"
+	"github.com/gfanton/grpc-quic/proto/hello"
+	quic "github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/qerr"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// admissionGate rejects every session until it is opened.
+type admissionGate struct {
+	mu       sync.Mutex
+	open     bool
+	admitted []tls.ConnectionState
+}
+
+func (g *admissionGate) admit(remote net.Addr, state tls.ConnectionState) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.open {
+		return qnet.Reject(0x142, "not on the allowlist")
+	}
+
+	g.admitted = append(g.admitted, state)
+	return nil
+}
+
+func (g *admissionGate) setOpen(open bool) {
+	g.mu.Lock()
+	g.open = open
+	g.mu.Unlock()
+}
+
+func TestAdmission(t *testing.T) {
+	var servers []*qgrpc.Server
+
+	defer func() {
+		for _, s := range servers {
+			s.Stop()
+		}
+	}()
+
+	gate := &admissionGate{}
+	rejected := make(chan error, 16)
+
+	Convey("Setup servers with an admission hook", t, func(c C) {
+		tlsConf, err := generateTLSConfig()
+		c.So(err, ShouldBeNil)
+
+		for _, native := range []bool{false, true} {
+			sopts := []opts.ServerOption{
+				opts.TLSConfig(tlsConf),
+				opts.Admission(gate.admit),
+				opts.AcceptErrorHandler(func(remote net.Addr, err error) { rejected <- err }),
+			}
+
+			laddr := "/ip4/127.0.0.1/udp/5901"
+			if native {
+				sopts = append(sopts, opts.NativeStreams())
+				laddr = "/ip4/127.0.0.1/udp/5902"
+			}
+
+			server, err := qgrpc.New(sopts...)
+			c.So(err, ShouldBeNil)
+			servers = append(servers, server)
+
+			hello.RegisterGreeterServer(server.Server, &Hello{})
+			go server.ListenAndServe(laddr)
+		}
+	})
+
+	Convey("Test rejected sessions are closed with the application code", t, func(c C) {
+		sess, err := quic.DialAddr("127.0.0.1:5901", &tls.Config{InsecureSkipVerify: true}, nil)
+		c.So(err, ShouldBeNil)
+
+		_, err = sess.AcceptStream()
+		quicErr, ok := err.(*qerr.QuicError)
+		c.So(ok, ShouldBeTrue)
+		c.So(uint64(quicErr.ErrorCode), ShouldEqual, 0x142)
+
+		select {
+		case err := <-rejected:
+			c.So(err, ShouldNotBeNil)
+		case <-time.After(time.Second):
+			c.So("rejection not reported", ShouldBeEmpty)
+		}
+	})
+
+	sayHello := func(target string, copts ...opts.DialOption) error {
+		copts = append(copts, opts.WithTLSConfig(&tls.Config{InsecureSkipVerify: true}))
+		client, err := qgrpc.Dial(target, copts...)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		_, err = hello.NewGreeterClient(client).SayHello(ctx, &hello.HelloRequest{Name: "World"})
+		return err
+	}
+
+	Convey("Test rejected clients cannot call the servers", t, func(c C) {
+		c.So(sayHello("/ip4/127.0.0.1/udp/5901"), ShouldNotBeNil)
+		c.So(sayHello("/ip4/127.0.0.1/udp/5902", opts.WithNativeStreams()), ShouldNotBeNil)
+	})
+
+	Convey("Test admitted clients call the servers", t, func(c C) {
+		gate.setOpen(true)
+
+		c.So(sayHello("/ip4/127.0.0.1/udp/5901"), ShouldBeNil)
+		c.So(sayHello("/ip4/127.0.0.1/udp/5902", opts.WithNativeStreams()), ShouldBeNil)
+
+		gate.mu.Lock()
+		defer gate.mu.Unlock()
+
+		c.So(len(gate.admitted), ShouldBeGreaterThanOrEqualTo, 2)
+		for _, state := range gate.admitted {
+			c.So(state.HandshakeComplete, ShouldBeTrue)
+		}
+	})
+}
@@ -0,0 +1,146 @@
+package test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	qgrpc "github.com/gfanton/grpc-quic"
+	quicbalancer "github.com/gfanton/grpc-quic/balancer"
+	"github.com/gfanton/grpc-quic/opts"
+	"github.com/gfanton/grpc-quic/proto/hello"
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+func TestQuicUpgrade(t *testing.T) {
+	var (
+		client  *grpc.ClientConn
+		servers []*grpc.Server
+	)
+
+	defer func() {
+		if client != nil {
+			client.Close()
+		}
+
+		for _, server := range servers {
+			server.Stop()
+		}
+	}()
+
+	Convey("Setup a TCP server advertising an UDP server", t, func(c C) {
+		tlsConf, err := generateTLSConfig()
+		c.So(err, ShouldBeNil)
+
+		_, _, err = qgrpc.NewServer("/ip4/127.0.0.1/tcp/5892", opts.AdvertiseQuic("/ip4/127.0.0.1/tcp/5892"))
+		c.So(err, ShouldNotBeNil)
+
+		for addr, opt := range map[string]opts.ServerOption{
+			"/ip4/127.0.0.1/udp/5892": opts.TLSConfig(tlsConf),
+			"/ip4/127.0.0.1/tcp/5892": opts.AdvertiseQuic("/ip4/127.0.0.1/udp/5892"),
+		} {
+			s, l, err := qgrpc.NewServer(addr, opts.TLSConfig(tlsConf), opt)
+			c.So(err, ShouldBeNil)
+
+			hello.RegisterGreeterServer(s, &Hello{})
+			servers = append(servers, s)
+
+			go s.Serve(l)
+		}
+	})
+
+	Convey("Test the client moves onto QUIC", t, func(c C) {
+		tlsConf := &tls.Config{InsecureSkipVerify: true}
+
+		var err error
+		client, err = qgrpc.Dial("/ip4/127.0.0.1/tcp/5892",
+			opts.WithTLSConfig(tlsConf),
+			opts.WithBalancerName(quicbalancer.Name),
+			opts.WithQuicUpgrade(),
+		)
+		c.So(err, ShouldBeNil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		greet := hello.NewGreeterClient(client)
+		var p peer.Peer
+		for p.Addr == nil || p.Addr.Network() != "udp" {
+			rep, err := greet.SayHello(ctx, &hello.HelloRequest{Name: "World"}, grpc.Peer(&p))
+			c.So(err, ShouldBeNil)
+			c.So(rep.GetMessage(), ShouldEqual, "Hello World")
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
+
+func TestQuicUpgradeOrigin(t *testing.T) {
+	var (
+		client  *grpc.ClientConn
+		servers []*grpc.Server
+	)
+
+	defer func() {
+		if client != nil {
+			client.Close()
+		}
+
+		for _, server := range servers {
+			server.Stop()
+		}
+	}()
+
+	ca, err := newTestCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Convey("Setup servers with a certificate for their hostname only", t, func(c C) {
+		cert, err := ca.issue(&x509.Certificate{
+			DNSNames:    []string{"localhost"},
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		})
+		c.So(err, ShouldBeNil)
+
+		tlsConf := &tls.Config{Certificates: []tls.Certificate{cert}}
+		for addr, opt := range map[string]opts.ServerOption{
+			"/ip4/127.0.0.1/udp/5911": opts.TLSConfig(tlsConf),
+			"/ip4/127.0.0.1/tcp/5911": opts.AdvertiseQuic("/ip4/127.0.0.1/udp/5911"),
+		} {
+			s, l, err := qgrpc.NewServer(addr, opts.TLSConfig(tlsConf), opt)
+			c.So(err, ShouldBeNil)
+
+			hello.RegisterGreeterServer(s, &Hello{})
+			servers = append(servers, s)
+
+			go s.Serve(l)
+		}
+	})
+
+	Convey("Test advertised addresses are verified for the origin", t, func(c C) {
+		var err error
+		client, err = qgrpc.Dial("/dns4/localhost/tcp/5911",
+			opts.WithTLSConfig(&tls.Config{RootCAs: ca.pool}),
+			opts.WithBalancerName(quicbalancer.Name),
+			opts.WithQuicUpgrade(),
+		)
+		c.So(err, ShouldBeNil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		greet := hello.NewGreeterClient(client)
+		var p peer.Peer
+		for ctx.Err() == nil && (p.Addr == nil || p.Addr.Network() != "udp") {
+			_, err := greet.SayHello(ctx, &hello.HelloRequest{Name: "World"}, grpc.Peer(&p))
+			c.So(err, ShouldBeNil)
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		c.So(p.Addr.Network(), ShouldEqual, "udp")
+	})
+}
@@ -0,0 +1,245 @@
+package test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"math/big"
+	"net/url"
+	"testing"
+	"time"
+
+	qgrpc "github.com/gfanton/grpc-quic"
+	quicauth "github.com/gfanton/grpc-quic/auth"
+	qnet "github.com/gfanton/grpc-quic/net"
+	"github.com/gfanton/grpc-quic/opts"
+	"github.com/gfanton/grpc-quic/proto/hello"
+	quic "github.com/lucas-clemente/quic-go"
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func generateClientCert(dnsName, uri string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{dnsName},
+		URIs:         []*url.URL{u},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+func TestAuthPolicy(t *testing.T) {
+	Convey("Test policy parsing", t, func(c C) {
+		_, err := quicauth.ParsePolicy([]byte(`{"rules": [{"method": "Greeter", "allow": ["*"]}]}`))
+		c.So(err, ShouldNotBeNil)
+
+		_, err = quicauth.ParsePolicy([]byte(`{"rules": [{"method": "*", "allow": ["sha256:00"]}]}`))
+		c.So(err, ShouldNotBeNil)
+
+		_, err = quicauth.ParsePolicy([]byte(`{"rules": [{"method": "*", "allow": ["user:bob"]}]}`))
+		c.So(err, ShouldNotBeNil)
+	})
+
+	Convey("Test policy rules", t, func(c C) {
+		cert, err := generateClientCert("client.example.com", "spiffe://example.com/client")
+		c.So(err, ShouldBeNil)
+
+		x509Cert, err := x509.ParseCertificate(cert.Certificate[0])
+		c.So(err, ShouldBeNil)
+		id := quicauth.NewIdentity(x509Cert)
+		id.Verified = true
+
+		p, err := quicauth.ParsePolicy([]byte(`{"rules": [
+			{"method": "/helloworld.Greeter/SayHello", "allow": ["dns:*.example.com"]},
+			{"method": "/admin.Admin/*", "allow": ["spiffe://example.com/admin", "sha256:` + id.KeyFingerprint + `"]},
+			{"method": "/public.Public/*", "allow": ["*"]}
+		]}`))
+		c.So(err, ShouldBeNil)
+
+		c.So(p.Authorize("/helloworld.Greeter/SayHello", id), ShouldBeNil)
+		c.So(p.Authorize("/admin.Admin/Close", id), ShouldBeNil)
+		c.So(p.Authorize("/public.Public/Get", nil), ShouldBeNil)
+
+		c.So(status.Code(p.Authorize("/helloworld.Greeter/SayHello", nil)), ShouldEqual, codes.PermissionDenied)
+		c.So(status.Code(p.Authorize("/other.Other/Get", id)), ShouldEqual, codes.PermissionDenied)
+
+		// only the fingerprints of unverified certificates are trusted
+		unverified := quicauth.NewIdentity(x509Cert)
+		c.So(status.Code(p.Authorize("/helloworld.Greeter/SayHello", unverified)), ShouldEqual, codes.PermissionDenied)
+		c.So(p.Authorize("/admin.Admin/Close", unverified), ShouldBeNil)
+	})
+}
+
+func TestAuthInterceptors(t *testing.T) {
+	var servers []*qgrpc.Server
+
+	defer func() {
+		for _, server := range servers {
+			server.Stop()
+		}
+	}()
+
+	ca, err := newTestCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientCert, err := ca.issue(&x509.Certificate{
+		URIs:        []*url.URL{{Scheme: "spiffe", Host: "example.com", Path: "/client"}},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a self-signed certificate claiming the same SPIFFE ID
+	selfSigned, err := generateClientCert("client.example.com", "spiffe://example.com/client")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// QUIC with TLS selects the certificate naming the server
+	serverCert, err := ca.issue(&x509.Certificate{DNSNames: []string{"localhost"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(c C, port string, clientAuth tls.ClientAuthType) {
+		tlsConf := &tls.Config{
+			Certificates: []tls.Certificate{serverCert},
+			ClientAuth:   clientAuth,
+			ClientCAs:    ca.pool,
+		}
+
+		p, err := quicauth.ParsePolicy([]byte(`{"rules": [
+			{"method": "/hello.Greeter/SayHello", "allow": ["spiffe://example.com/client"]}
+		]}`))
+		c.So(err, ShouldBeNil)
+
+		server, err := qgrpc.New(
+			opts.TLSConfig(tlsConf),
+			opts.QuicVersions(qnet.VersionTLS),
+			opts.UnaryInterceptor(quicauth.UnaryServerInterceptor(p)),
+			opts.StreamInterceptor(quicauth.StreamServerInterceptor(p)),
+		)
+		c.So(err, ShouldBeNil)
+		servers = append(servers, server)
+
+		hello.RegisterGreeterServer(server.Server, &Hello{})
+		for _, laddr := range []string{"/ip4/127.0.0.1/udp/" + port, "/ip4/127.0.0.1/tcp/" + port} {
+			l, err := server.Listen(laddr)
+			c.So(err, ShouldBeNil)
+			go server.Serve(l)
+		}
+	}
+
+	Convey("Setup servers verifying and only requesting client certificates", t, func(c C) {
+		serve(c, "5899", tls.RequireAndVerifyClientCert)
+		serve(c, "5914", tls.RequestClientCert)
+	})
+
+	sayHello := func(target string, certs ...tls.Certificate) error {
+		tlsConf := &tls.Config{ServerName: "localhost", InsecureSkipVerify: true, Certificates: certs}
+		client, err := qgrpc.Dial(target, opts.WithTLSConfig(tlsConf), opts.WithQuicVersions(qnet.VersionTLS))
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		_, err = hello.NewGreeterClient(client).SayHello(ctx, &hello.HelloRequest{Name: "World"})
+		return err
+	}
+
+	Convey("Test peers with a verified identity are authorized on QUIC and TCP", t, func(c C) {
+		for _, target := range []string{"/ip4/127.0.0.1/udp/5899", "/ip4/127.0.0.1/tcp/5899"} {
+			err := sayHello(target, clientCert)
+			c.So(err, ShouldBeNil)
+		}
+	})
+
+	Convey("Test peers without a certificate are denied on QUIC and TCP", t, func(c C) {
+		for _, target := range []string{"/ip4/127.0.0.1/udp/5914", "/ip4/127.0.0.1/tcp/5914"} {
+			err := sayHello(target)
+			c.So(status.Code(err), ShouldEqual, codes.PermissionDenied)
+		}
+	})
+
+	Convey("Test unverified certificates claiming an allowed SPIFFE ID are denied on QUIC and TCP", t, func(c C) {
+		for _, target := range []string{"/ip4/127.0.0.1/udp/5914", "/ip4/127.0.0.1/tcp/5914"} {
+			err := sayHello(target, selfSigned)
+			c.So(status.Code(err), ShouldEqual, codes.PermissionDenied)
+		}
+	})
+}
+
+func TestAuthAdmission(t *testing.T) {
+	var server *qgrpc.Server
+
+	defer func() {
+		if server != nil {
+			server.Stop()
+		}
+	}()
+
+	Convey("Setup server admitting the peers allowed by its policy", t, func(c C) {
+		tlsConf, err := generateTLSConfig()
+		c.So(err, ShouldBeNil)
+
+		p, err := quicauth.ParsePolicy([]byte(`{"rules": [
+			{"method": "*", "allow": ["spiffe://example.com/client"]}
+		]}`))
+		c.So(err, ShouldBeNil)
+
+		server, err = qgrpc.New(opts.TLSConfig(tlsConf), opts.Admission(p.Admission()))
+		c.So(err, ShouldBeNil)
+
+		l, err := server.Listen("/ip4/127.0.0.1/udp/5915")
+		c.So(err, ShouldBeNil)
+
+		hello.RegisterGreeterServer(server.Server, &Hello{})
+		go server.Serve(l)
+	})
+
+	Convey("Test anonymous peers are closed with AuthFailureCode", t, func(c C) {
+		sess, err := quic.DialAddr("127.0.0.1:5915", &tls.Config{InsecureSkipVerify: true}, nil)
+		c.So(err, ShouldBeNil)
+
+		_, err = sess.AcceptStream()
+		err = qnet.WrapError("127.0.0.1:5915", err)
+
+		code, ok := qnet.CloseCode(err)
+		c.So(ok, ShouldBeTrue)
+		c.So(code, ShouldEqual, qnet.AuthFailureCode)
+
+		var resetErr *qnet.PeerResetError
+		c.So(errors.As(err, &resetErr), ShouldBeTrue)
+		c.So(resetErr.Temporary(), ShouldBeFalse)
+	})
+}
@@ -0,0 +1,189 @@
+package test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"testing"
+	"time"
+
+	qgrpc "github.com/gfanton/grpc-quic"
+	qnet "github.com/gfanton/grpc-quic/net"
+	"github.com/gfanton/grpc-quic/opts"
+	"github.com/gfanton/grpc-quic/proto/hello"
+	"github.com/gfanton/grpc-quic/transports"
+	quic "github.com/lucas-clemente/quic-go"
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+func TestAuthInfoTLSState(t *testing.T) {
+	var server *qgrpc.Server
+
+	defer func() {
+		if server != nil {
+			server.Stop()
+		}
+	}()
+
+	states := make(chan tls.ConnectionState, 1)
+
+	Convey("Setup server", t, func(c C) {
+		tlsConf, err := generateTLSConfig()
+		c.So(err, ShouldBeNil)
+
+		// the peers of QUIC and TCP connections carry a credentials.TLSInfo
+		record := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if p, ok := peer.FromContext(ctx); ok {
+				if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+					states <- info.State
+				}
+			}
+			return handler(ctx, req)
+		}
+
+		server, err = qgrpc.New(opts.TLSConfig(tlsConf), opts.UnaryInterceptor(record))
+		c.So(err, ShouldBeNil)
+
+		hello.RegisterGreeterServer(server.Server, &Hello{})
+		for _, laddr := range []string{"/ip4/127.0.0.1/udp/5898", "/ip4/127.0.0.1/tcp/5898"} {
+			l, err := server.Listen(laddr)
+			c.So(err, ShouldBeNil)
+			go server.Serve(l)
+		}
+	})
+
+	Convey("Test the TLS state has the same shape over QUIC and TCP", t, func(c C) {
+		tlsConf := &tls.Config{InsecureSkipVerify: true}
+
+		protocols := map[string]string{
+			"/ip4/127.0.0.1/udp/5898": "quic-tls",
+			"/ip4/127.0.0.1/tcp/5898": "tls",
+		}
+
+		for target, protocol := range protocols {
+			client, err := qgrpc.Dial(target, opts.WithTLSConfig(tlsConf))
+			c.So(err, ShouldBeNil)
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			var p peer.Peer
+			_, err = hello.NewGreeterClient(client).SayHello(ctx, &hello.HelloRequest{Name: "World"}, grpc.Peer(&p))
+			cancel()
+			client.Close()
+			c.So(err, ShouldBeNil)
+
+			// client side
+			_, ok := p.AuthInfo.(credentials.TLSInfo)
+			c.So(ok, ShouldBeTrue)
+
+			state, ok := transports.TLSState(p.AuthInfo)
+			c.So(ok, ShouldBeTrue)
+			c.So(state.HandshakeComplete, ShouldBeTrue)
+			c.So(state.PeerCertificates, ShouldHaveLength, 1)
+
+			info := transports.ProtocolInfo(p.AuthInfo)
+			c.So(info.SecurityProtocol, ShouldEqual, protocol)
+			c.So(info.SecurityVersion, ShouldNotBeEmpty)
+
+			// server side
+			select {
+			case state := <-states:
+				c.So(state.HandshakeComplete, ShouldBeTrue)
+			case <-time.After(time.Second):
+				c.So("no server side TLS state", ShouldBeEmpty)
+			}
+		}
+	})
+}
+
+func TestAuthInfoClientCert(t *testing.T) {
+	var server *qgrpc.Server
+
+	defer func() {
+		if server != nil {
+			server.Stop()
+		}
+	}()
+
+	states := make(chan tls.ConnectionState, 1)
+
+	Convey("Setup server requesting client certificates", t, func(c C) {
+		ca, err := newTestCA()
+		c.So(err, ShouldBeNil)
+
+		// QUIC with TLS selects the certificate naming the server
+		serverCert, err := ca.issue(&x509.Certificate{DNSNames: []string{"localhost"}})
+		c.So(err, ShouldBeNil)
+
+		tlsConf := &tls.Config{Certificates: []tls.Certificate{serverCert}, ClientAuth: tls.RequireAnyClientCert}
+
+		record := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if p, ok := peer.FromContext(ctx); ok {
+				if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+					states <- info.State
+				}
+			}
+			return handler(ctx, req)
+		}
+
+		// gQUIC cannot carry client certificates
+		gquic, err := qgrpc.New(opts.TLSConfig(tlsConf))
+		c.So(err, ShouldBeNil)
+
+		_, err = gquic.Listen("/ip4/127.0.0.1/udp/5913")
+		c.So(errors.Is(err, qgrpc.ErrQuicClientAuth), ShouldBeTrue)
+
+		server, err = qgrpc.New(
+			opts.TLSConfig(tlsConf),
+			opts.QuicVersions(qnet.VersionTLS),
+			opts.UnaryInterceptor(record),
+		)
+		c.So(err, ShouldBeNil)
+
+		hello.RegisterGreeterServer(server.Server, &Hello{})
+		for _, laddr := range []string{"/ip4/127.0.0.1/udp/5913", "/ip4/127.0.0.1/tcp/5913"} {
+			l, err := server.Listen(laddr)
+			c.So(err, ShouldBeNil)
+			go server.Serve(l)
+		}
+	})
+
+	Convey("Test the server sees the certificate of the client over QUIC and TCP", t, func(c C) {
+		clientCert, err := generateClientCert("client.example.com", "spiffe://example.com/client")
+		c.So(err, ShouldBeNil)
+
+		tlsConf := &tls.Config{ServerName: "localhost", InsecureSkipVerify: true, Certificates: []tls.Certificate{clientCert}}
+		for _, target := range []string{"/ip4/127.0.0.1/udp/5913", "/ip4/127.0.0.1/tcp/5913"} {
+			client, err := qgrpc.Dial(target, opts.WithTLSConfig(tlsConf), opts.WithQuicVersions(qnet.VersionTLS))
+			c.So(err, ShouldBeNil)
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			_, err = hello.NewGreeterClient(client).SayHello(ctx, &hello.HelloRequest{Name: "World"})
+			cancel()
+			client.Close()
+			c.So(err, ShouldBeNil)
+
+			select {
+			case state := <-states:
+				c.So(state.PeerCertificates, ShouldHaveLength, 1)
+				c.So(state.PeerCertificates[0].Raw, ShouldResemble, clientCert.Certificate[0])
+			case <-time.After(time.Second):
+				c.So("no server side TLS state", ShouldBeEmpty)
+			}
+		}
+	})
+
+	Convey("Test QUIC clients without a certificate are closed with AuthFailureCode", t, func(c C) {
+		qconf := &quic.Config{Versions: []quic.VersionNumber{qnet.VersionTLS}}
+		sess, err := quic.DialAddr("127.0.0.1:5913", &tls.Config{ServerName: "localhost", InsecureSkipVerify: true}, qconf)
+		c.So(err, ShouldBeNil)
+
+		_, err = sess.AcceptStream()
+		code, ok := qnet.CloseCode(qnet.WrapError("127.0.0.1:5913", err))
+		c.So(ok, ShouldBeTrue)
+		c.So(code, ShouldEqual, qnet.AuthFailureCode)
+	})
+}
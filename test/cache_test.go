@@ -0,0 +1,206 @@
+package test
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	qgrpc "github.com/gfanton/grpc-quic"
+	quiccache "github.com/gfanton/grpc-quic/cache"
+	"github.com/gfanton/grpc-quic/opts"
+	"github.com/gfanton/grpc-quic/proto/hello"
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+func TestQuicCacheFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "quiccache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "quic.json")
+
+	Convey("Test entries are persisted", t, func(c C) {
+		cache, err := quiccache.NewFileCache(path, time.Minute)
+		c.So(err, ShouldBeNil)
+
+		_, ok := cache.Lookup("udp4", "example.com:443")
+		c.So(ok, ShouldBeFalse)
+
+		cache.Store("udp4", "example.com:443", false)
+		cache.Store("udp6", "example.com:443", true)
+
+		cache, err = quiccache.NewFileCache(path, time.Minute)
+		c.So(err, ShouldBeNil)
+
+		works, ok := cache.Lookup("udp4", "example.com:443")
+		c.So(ok, ShouldBeTrue)
+		c.So(works, ShouldBeFalse)
+
+		works, ok = cache.Lookup("udp6", "example.com:443")
+		c.So(ok, ShouldBeTrue)
+		c.So(works, ShouldBeTrue)
+	})
+
+	Convey("Test entries expire", t, func(c C) {
+		cache := quiccache.NewMemoryCache(10 * time.Millisecond)
+		cache.Store("udp4", "example.com:443", true)
+		time.Sleep(20 * time.Millisecond)
+
+		_, ok := cache.Lookup("udp4", "example.com:443")
+		c.So(ok, ShouldBeFalse)
+	})
+}
+
+func TestQuicCacheDial(t *testing.T) {
+	var (
+		client  *grpc.ClientConn
+		servers []*grpc.Server
+	)
+
+	defer func() {
+		if client != nil {
+			client.Close()
+		}
+
+		for _, server := range servers {
+			server.Stop()
+		}
+	}()
+
+	Convey("Setup servers", t, func(c C) {
+		tlsConf, err := generateTLSConfig()
+		c.So(err, ShouldBeNil)
+
+		for _, addr := range []string{"/ip4/127.0.0.1/udp/5890", "/ip4/127.0.0.1/tcp/5891"} {
+			s, l, err := qgrpc.NewServer(addr, opts.TLSConfig(tlsConf))
+			c.So(err, ShouldBeNil)
+
+			hello.RegisterGreeterServer(s, &Hello{})
+			servers = append(servers, s)
+
+			go s.Serve(l)
+		}
+	})
+
+	Convey("Test a working QUIC dial is recorded", t, func(c C) {
+		cache := quiccache.NewMemoryCache(time.Minute)
+		conn, err := qgrpc.Dial("/ip4/127.0.0.1/udp/5890",
+			opts.WithTLSConfig(&tls.Config{InsecureSkipVerify: true}),
+			opts.WithQuicCache(cache),
+		)
+		c.So(err, ShouldBeNil)
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		_, err = hello.NewGreeterClient(conn).SayHello(ctx, &hello.HelloRequest{Name: "World"})
+		c.So(err, ShouldBeNil)
+
+		works, ok := cache.Lookup("udp4", "127.0.0.1:5890")
+		c.So(ok, ShouldBeTrue)
+		c.So(works, ShouldBeTrue)
+	})
+
+	Convey("Test a blocked host is dialed over TCP first", t, func(c C) {
+		cache := quiccache.NewMemoryCache(time.Minute)
+		cache.Store("udp4", "127.0.0.1:5891", false)
+
+		var err error
+		client, err = qgrpc.Dial("/ip4/127.0.0.1/udp/5891",
+			opts.WithTLSConfig(&tls.Config{InsecureSkipVerify: true}),
+			opts.WithQuicCache(cache),
+		)
+		c.So(err, ShouldBeNil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		var p peer.Peer
+		rep, err := hello.NewGreeterClient(client).SayHello(ctx, &hello.HelloRequest{Name: "World"}, grpc.Peer(&p))
+		c.So(err, ShouldBeNil)
+		c.So(rep.GetMessage(), ShouldEqual, "Hello World")
+		c.So(p.Addr.Network(), ShouldEqual, "tcp")
+	})
+}
+
+func TestQuicCacheBlackhole(t *testing.T) {
+	var (
+		server    *grpc.Server
+		blackhole net.PacketConn
+	)
+
+	defer func() {
+		if blackhole != nil {
+			blackhole.Close()
+		}
+
+		if server != nil {
+			server.Stop()
+		}
+	}()
+
+	Convey("Setup a TCP server and drop UDP on the same port", t, func(c C) {
+		tlsConf, err := generateTLSConfig()
+		c.So(err, ShouldBeNil)
+
+		s, l, err := qgrpc.NewServer("/ip4/127.0.0.1/tcp/5910", opts.TLSConfig(tlsConf))
+		c.So(err, ShouldBeNil)
+
+		hello.RegisterGreeterServer(s, &Hello{})
+		server = s
+
+		go s.Serve(l)
+
+		// the socket is never read, so QUIC handshakes get no answer
+		blackhole, err = net.ListenPacket("udp", "127.0.0.1:5910")
+		c.So(err, ShouldBeNil)
+	})
+
+	Convey("Test a blackholed QUIC is skipped by the next dial", t, func(c C) {
+		cache := quiccache.NewMemoryCache(time.Minute)
+		tlsConf := &tls.Config{InsecureSkipVerify: true}
+
+		sayHello := func(conn *grpc.ClientConn) string {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			var p peer.Peer
+			_, err := hello.NewGreeterClient(conn).SayHello(ctx, &hello.HelloRequest{Name: "World"}, grpc.Peer(&p))
+			c.So(err, ShouldBeNil)
+			return p.Addr.Network()
+		}
+
+		conn, err := qgrpc.Dial("/ip4/127.0.0.1/udp/5910",
+			opts.WithTLSConfig(tlsConf),
+			opts.WithQuicCache(cache),
+			opts.WithHappyEyeballs(100*time.Millisecond),
+		)
+		c.So(err, ShouldBeNil)
+		c.So(sayHello(conn), ShouldEqual, "tcp")
+		conn.Close()
+
+		works, ok := cache.Lookup("udp4", "127.0.0.1:5910")
+		c.So(ok, ShouldBeTrue)
+		c.So(works, ShouldBeFalse)
+
+		// without happy eyeballs, dialing QUIC first would not complete
+		// within the call timeout
+		conn, err = qgrpc.Dial("/ip4/127.0.0.1/udp/5910",
+			opts.WithTLSConfig(tlsConf),
+			opts.WithQuicCache(cache),
+		)
+		c.So(err, ShouldBeNil)
+		c.So(sayHello(conn), ShouldEqual, "tcp")
+		conn.Close()
+	})
+}
@@ -0,0 +1,204 @@
+package test
+
+import (
+	"context"
+	"crypto/x509"
+	"strings"
+	"testing"
+	"time"
+
+	qgrpc "github.com/gfanton/grpc-quic"
+	qnet "github.com/gfanton/grpc-quic/net"
+	"github.com/gfanton/grpc-quic/opts"
+	"github.com/gfanton/grpc-quic/proto/hello"
+	ma "github.com/multiformats/go-multiaddr"
+	madns "github.com/multiformats/go-multiaddr-dns"
+	mh "github.com/multiformats/go-multihash"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCertHash(t *testing.T) {
+	var (
+		server *qgrpc.Server
+		pinned []ma.Multiaddr
+	)
+
+	defer func() {
+		if server != nil {
+			server.Stop()
+		}
+	}()
+
+	Convey("Test certhash multiaddrs", t, func(c C) {
+		sum, err := mh.Sum([]byte("certificate"), mh.SHA2_256, -1)
+		c.So(err, ShouldBeNil)
+
+		m, err := ma.NewMultiaddr("/ip4/127.0.0.1/udp/4433/certhash/z" + sum.B58String())
+		c.So(err, ShouldBeNil)
+		c.So(qnet.CertHashes(m), ShouldResemble, []mh.Multihash{sum})
+
+		m, err = ma.NewMultiaddr(m.String())
+		c.So(err, ShouldBeNil)
+		c.So(qnet.CertHashes(m), ShouldResemble, []mh.Multihash{sum})
+
+		_, _, err = qnet.ParseMultiaddr(m)
+		c.So(err, ShouldBeNil)
+
+		_, err = ma.NewMultiaddr("/ip4/127.0.0.1/udp/4433/certhash/xinvalid")
+		c.So(err, ShouldNotBeNil)
+
+		c.So(qnet.WithoutCertHash(m).String(), ShouldEqual, "/ip4/127.0.0.1/udp/4433")
+	})
+
+	Convey("Test certhash only accepts full digests of SHA-256 or stronger", t, func(c C) {
+		for _, code := range []uint64{mh.SHA2_512, mh.SHA3_256} {
+			sum, err := mh.Sum([]byte("certificate"), code, -1)
+			c.So(err, ShouldBeNil)
+
+			_, err = ma.NewMultiaddr("/ip4/127.0.0.1/udp/4433/certhash/z" + sum.B58String())
+			c.So(err, ShouldBeNil)
+		}
+
+		weak, err := mh.Sum([]byte("certificate"), mh.SHA1, -1)
+		c.So(err, ShouldBeNil)
+
+		truncated, err := mh.Sum([]byte("certificate"), mh.SHA2_256, 8)
+		c.So(err, ShouldBeNil)
+
+		for _, sum := range []mh.Multihash{weak, truncated} {
+			_, err = ma.NewMultiaddr("/ip4/127.0.0.1/udp/4433/certhash/z" + sum.B58String())
+			c.So(err, ShouldNotBeNil)
+		}
+	})
+
+	Convey("Setup server with a self-signed certificate", t, func(c C) {
+		tlsConf, err := generateTLSConfig()
+		c.So(err, ShouldBeNil)
+
+		server, err = qgrpc.New(opts.TLSConfig(tlsConf))
+		c.So(err, ShouldBeNil)
+
+		hello.RegisterGreeterServer(server.Server, &Hello{})
+
+		for _, addr := range []string{"/ip4/127.0.0.1/udp/5897", "/ip4/127.0.0.1/tcp/5897"} {
+			l, err := server.Listen(addr)
+			c.So(err, ShouldBeNil)
+
+			go server.Serve(l)
+		}
+
+		pinned, err = server.PinnedAddrs()
+		c.So(err, ShouldBeNil)
+		c.So(pinned, ShouldHaveLength, 2)
+	})
+
+	Convey("Test dial verifies the pinned certificate", t, func(c C) {
+		for _, m := range pinned {
+			client, err := qgrpc.Dial(m.String(), opts.WithBlock(), opts.WithTimeout(time.Second))
+			c.So(err, ShouldBeNil)
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			rep, err := hello.NewGreeterClient(client).SayHello(ctx, &hello.HelloRequest{Name: "World"})
+			cancel()
+			client.Close()
+
+			c.So(err, ShouldBeNil)
+			c.So(rep.GetMessage(), ShouldEqual, "Hello World")
+		}
+	})
+
+	Convey("Test wildcard listeners are pinned on the interface addresses", t, func(c C) {
+		l, err := server.Listen("/ip4/0.0.0.0/udp/5912")
+		c.So(err, ShouldBeNil)
+
+		go server.Serve(l)
+
+		addrs, err := server.PinnedAddrs()
+		c.So(err, ShouldBeNil)
+
+		var loopback ma.Multiaddr
+		for _, m := range addrs {
+			c.So(strings.HasPrefix(m.String(), "/ip4/0.0.0.0/"), ShouldBeFalse)
+			if strings.HasPrefix(m.String(), "/ip4/127.0.0.1/udp/5912/") {
+				loopback = m
+			}
+		}
+		c.So(loopback, ShouldNotBeNil)
+
+		client, err := qgrpc.Dial(loopback.String(), opts.WithBlock(), opts.WithTimeout(time.Second))
+		c.So(err, ShouldBeNil)
+		client.Close()
+	})
+
+	Convey("Test dial rejects another certificate", t, func(c C) {
+		other, err := generateTLSConfig()
+		c.So(err, ShouldBeNil)
+
+		cert, err := x509.ParseCertificate(other.Certificates[0].Certificate[0])
+		c.So(err, ShouldBeNil)
+
+		for _, m := range pinned {
+			addr := strings.Split(m.String(), "/certhash/")[0]
+			base, err := ma.NewMultiaddr(addr)
+			c.So(err, ShouldBeNil)
+
+			wrong, err := qnet.WithCertHash(base, cert)
+			c.So(err, ShouldBeNil)
+
+			_, err = qgrpc.Dial(wrong.String(),
+				opts.WithBlock(),
+				opts.WithTimeout(500*time.Millisecond),
+				opts.FailOnNonTempDialError(true),
+			)
+			c.So(err, ShouldNotBeNil)
+		}
+	})
+}
+
+func TestCertHashDNSAddr(t *testing.T) {
+	var server *qgrpc.Server
+
+	defer func() {
+		if server != nil {
+			server.Stop()
+		}
+	}()
+
+	var spoofed ma.Multiaddr
+
+	Convey("Setup server with a self-signed certificate", t, func(c C) {
+		tlsConf, err := generateTLSConfig()
+		c.So(err, ShouldBeNil)
+
+		server, err = qgrpc.New(opts.TLSConfig(tlsConf))
+		c.So(err, ShouldBeNil)
+
+		hello.RegisterGreeterServer(server.Server, &Hello{})
+
+		l, err := server.Listen("/ip4/127.0.0.1/udp/5916")
+		c.So(err, ShouldBeNil)
+
+		go server.Serve(l)
+
+		pinned, err := server.PinnedAddrs()
+		c.So(err, ShouldBeNil)
+		c.So(pinned, ShouldHaveLength, 1)
+		spoofed = pinned[0]
+	})
+
+	Convey("Test dnsaddr records cannot pin the certificate", t, func(c C) {
+		backend := &madns.MockBackend{
+			TXT: map[string][]string{
+				"_dnsaddr.example.com": {"dnsaddr=" + spoofed.String()},
+			},
+		}
+
+		_, err := qgrpc.Dial("/dnsaddr/example.com",
+			opts.WithDNSResolver(backend),
+			opts.WithBlock(),
+			opts.WithTimeout(500*time.Millisecond),
+			opts.FailOnNonTempDialError(true),
+		)
+		c.So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,101 @@
+package test
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	qnet "github.com/gfanton/grpc-quic/net"
+	quic "github.com/lucas-clemente/quic-go"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGracefulClose(t *testing.T) {
+	var (
+		listener *qnet.Listener
+		client   net.Conn
+	)
+
+	defer func() {
+		if client != nil {
+			client.Close()
+		}
+
+		if listener != nil {
+			listener.Close()
+		}
+	}()
+
+	Convey("Setup listener", t, func(c C) {
+		tlsConf, err := generateTLSConfig()
+		c.So(err, ShouldBeNil)
+
+		ql, err := quic.ListenAddr("127.0.0.1:5904", tlsConf, nil)
+		c.So(err, ShouldBeNil)
+
+		listener = qnet.NewListener(ql, nil)
+	})
+
+	payload := bytes.Repeat([]byte("grpc-quic"), 128*1024)
+
+	Convey("Test queued data is flushed before the session is closed", t, func(c C) {
+		served := make(chan error, 1)
+		go func() {
+			conn, err := listener.Accept()
+			if err != nil {
+				served <- err
+				return
+			}
+
+			if _, err := conn.Read(make([]byte, 1)); err != nil {
+				served <- err
+				return
+			}
+
+			if _, err := conn.Write(payload); err != nil {
+				served <- err
+				return
+			}
+
+			served <- conn.(*qnet.Conn).CloseWithError(qnet.GoAwayCode, "moving to another server")
+		}()
+
+		sess, err := quic.DialAddr("127.0.0.1:5904", &tls.Config{InsecureSkipVerify: true}, nil)
+		c.So(err, ShouldBeNil)
+
+		client, err = qnet.NewConn(sess)
+		c.So(err, ShouldBeNil)
+
+		_, err = client.Write([]byte{0})
+		c.So(err, ShouldBeNil)
+
+		data, err := ioutil.ReadAll(client)
+		c.So(err, ShouldBeNil)
+		c.So(bytes.Equal(data, payload), ShouldBeTrue)
+
+		select {
+		case <-sess.Context().Done():
+		case <-time.After(5 * time.Second):
+			c.So("session still open", ShouldBeEmpty)
+		}
+		c.So(<-served, ShouldBeNil)
+
+		_, err = client.Write([]byte{0})
+		var resetErr *qnet.PeerResetError
+		c.So(err, ShouldHaveSameTypeAs, resetErr)
+
+		code, ok := qnet.CloseCode(err)
+		c.So(ok, ShouldBeTrue)
+		c.So(code, ShouldEqual, qnet.GoAwayCode)
+		c.So(err.Error(), ShouldContainSubstring, "moving to another server")
+		c.So(err.Error(), ShouldContainSubstring, "go away")
+	})
+
+	Convey("Test closing twice fails", t, func(c C) {
+		c.So(client.Close(), ShouldBeNil)
+		c.So(client.Close(), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,78 @@
+package test
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+	"time"
+
+	qgrpc "github.com/gfanton/grpc-quic"
+	"github.com/gfanton/grpc-quic/opts"
+	"github.com/gfanton/grpc-quic/proto/hello"
+	quic "github.com/lucas-clemente/quic-go"
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/grpc"
+)
+
+func TestQuicConfig(t *testing.T) {
+	var server *grpc.Server
+
+	defer func() {
+		if server != nil {
+			server.Stop()
+		}
+	}()
+
+	Convey("Test invalid QUIC options", t, func(c C) {
+		_, _, err := qgrpc.NewServer("/ip4/127.0.0.1/udp/5893", opts.ConnectionIDLength(2))
+		c.So(err, ShouldNotBeNil)
+
+		_, err = qgrpc.Dial("/ip4/127.0.0.1/udp/5893", opts.WithFlowControlWindows(2<<20, 1<<20))
+		c.So(err, ShouldNotBeNil)
+	})
+
+	Convey("Setup server", t, func(c C) {
+		tlsConf, err := generateTLSConfig()
+		c.So(err, ShouldBeNil)
+
+		s, l, err := qgrpc.NewServer("/ip4/127.0.0.1/udp/5893",
+			opts.TLSConfig(tlsConf),
+			opts.QuicConfig(&quic.Config{KeepAlive: true}),
+			opts.QuicVersions(quic.VersionGQUIC39),
+			opts.IdleTimeout(time.Minute),
+			opts.MaxIncomingStreams(10),
+		)
+		c.So(err, ShouldBeNil)
+
+		hello.RegisterGreeterServer(s, &Hello{})
+		server = s
+
+		go s.Serve(l)
+	})
+
+	Convey("Test each ClientConn uses its own QUIC config", t, func(c C) {
+		tlsConf := &tls.Config{InsecureSkipVerify: true}
+		call := func(versions ...quic.VersionNumber) error {
+			client, err := qgrpc.Dial("/ip4/127.0.0.1/udp/5893",
+				opts.WithTLSConfig(tlsConf),
+				opts.WithQuicVersions(versions...),
+				opts.WithHandshakeTimeout(time.Second),
+				opts.WithBlock(),
+				opts.WithTimeout(time.Second),
+			)
+			if err != nil {
+				return err
+			}
+			defer client.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			_, err = hello.NewGreeterClient(client).SayHello(ctx, &hello.HelloRequest{Name: "World"})
+			return err
+		}
+
+		c.So(call(quic.VersionGQUIC43), ShouldNotBeNil)
+		c.So(call(quic.VersionGQUIC39), ShouldBeNil)
+	})
+}
@@ -0,0 +1,120 @@
+package test
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+	"time"
+
+	qgrpc "github.com/gfanton/grpc-quic"
+	"github.com/gfanton/grpc-quic/opts"
+	"github.com/gfanton/grpc-quic/proto/hello"
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+func TestDialHappyEyeballs(t *testing.T) {
+	var (
+		client *grpc.ClientConn
+		server *grpc.Server
+	)
+
+	defer func() {
+		if client != nil {
+			client.Close()
+		}
+
+		if server != nil {
+			server.Stop()
+		}
+	}()
+
+	Convey("Setup TCP server", t, func(c C) {
+		tlsConf, err := generateTLSConfig()
+		c.So(err, ShouldBeNil)
+
+		s, l, err := qgrpc.NewServer("/ip4/127.0.0.1/tcp/5880", opts.TLSConfig(tlsConf))
+		c.So(err, ShouldBeNil)
+
+		hello.RegisterGreeterServer(s, &Hello{})
+		server = s
+
+		go func() {
+			err := s.Serve(l)
+			c.So(err, ShouldBeNil)
+		}()
+	})
+
+	Convey("Test UDP dial falls back on TCP", t, func(c C) {
+		tlsConf := &tls.Config{InsecureSkipVerify: true}
+
+		var err error
+		client, err = qgrpc.Dial("/ip4/127.0.0.1/udp/5880",
+			opts.WithTLSConfig(tlsConf),
+			opts.WithHappyEyeballs(100*time.Millisecond),
+		)
+		c.So(err, ShouldBeNil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		rep, err := hello.NewGreeterClient(client).SayHello(ctx, &hello.HelloRequest{Name: "World"})
+		c.So(err, ShouldBeNil)
+		c.So(rep.GetMessage(), ShouldEqual, "Hello World")
+	})
+}
+
+func TestDialHappyEyeballsQuicWins(t *testing.T) {
+	var (
+		client  *grpc.ClientConn
+		servers []*grpc.Server
+	)
+
+	defer func() {
+		if client != nil {
+			client.Close()
+		}
+
+		for _, server := range servers {
+			server.Stop()
+		}
+	}()
+
+	Convey("Setup UDP and TCP servers", t, func(c C) {
+		tlsConf, err := generateTLSConfig()
+		c.So(err, ShouldBeNil)
+
+		for _, target := range []string{"/ip4/127.0.0.1/udp/5881", "/ip4/127.0.0.1/tcp/5881"} {
+			s, l, err := qgrpc.NewServer(target, opts.TLSConfig(tlsConf))
+			c.So(err, ShouldBeNil)
+
+			hello.RegisterGreeterServer(s, &Hello{})
+			servers = append(servers, s)
+
+			go func() {
+				err := s.Serve(l)
+				c.So(err, ShouldBeNil)
+			}()
+		}
+	})
+
+	Convey("Test QUIC wins with the default head start", t, func(c C) {
+		tlsConf := &tls.Config{InsecureSkipVerify: true}
+
+		var err error
+		client, err = qgrpc.Dial("/ip4/127.0.0.1/udp/5881",
+			opts.WithTLSConfig(tlsConf),
+			opts.WithHappyEyeballs(0),
+		)
+		c.So(err, ShouldBeNil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		var p peer.Peer
+		_, err = hello.NewGreeterClient(client).SayHello(ctx, &hello.HelloRequest{Name: "World"}, grpc.Peer(&p))
+		c.So(err, ShouldBeNil)
+		c.So(p.Addr.Network(), ShouldEqual, "udp")
+	})
+}
@@ -0,0 +1,115 @@
+package test
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	qgrpc "github.com/gfanton/grpc-quic"
+	qnet "github.com/gfanton/grpc-quic/net"
+	"github.com/gfanton/grpc-quic/opts"
+	quic "github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/qerr"
+	ma "github.com/multiformats/go-multiaddr"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type temporary interface {
+	Temporary() bool
+}
+
+func mustMultiaddr(s string) ma.Multiaddr {
+	m, err := ma.NewMultiaddr(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return m
+}
+
+func TestErrors(t *testing.T) {
+	Convey("Test QUIC errors are mapped to typed errors", t, func(c C) {
+		var handshakeErr *qnet.HandshakeTimeoutError
+		err := qnet.WrapError("127.0.0.1:5903", qerr.Error(qerr.HandshakeTimeout, "too slow"))
+		c.So(errors.As(err, &handshakeErr), ShouldBeTrue)
+		c.So(handshakeErr.Temporary(), ShouldBeTrue)
+		c.So(handshakeErr.Timeout(), ShouldBeTrue)
+
+		var idleErr *qnet.IdleTimeoutError
+		err = qnet.WrapError("127.0.0.1:5903", qerr.Error(qerr.NetworkIdleTimeout, "idle"))
+		c.So(errors.As(err, &idleErr), ShouldBeTrue)
+		c.So(idleErr.Temporary(), ShouldBeTrue)
+
+		var versionErr *qnet.VersionMismatchError
+		err = qnet.WrapError("127.0.0.1:5903", qerr.InvalidVersion)
+		c.So(errors.As(err, &versionErr), ShouldBeTrue)
+		c.So(versionErr.Temporary(), ShouldBeFalse)
+
+		var resetErr *qnet.PeerResetError
+		err = qnet.WrapError("127.0.0.1:5903", qerr.Error(qerr.ErrorCode(qnet.GoAwayCode), "go away"))
+		c.So(errors.As(err, &resetErr), ShouldBeTrue)
+		c.So(resetErr.Temporary(), ShouldBeTrue)
+		c.So(resetErr.Code, ShouldEqual, qnet.GoAwayCode)
+		c.So(resetErr.Reason, ShouldEqual, "go away")
+
+		// the QUIC error is still there
+		var quicErr *qerr.QuicError
+		c.So(errors.As(err, &quicErr), ShouldBeTrue)
+
+		other := errors.New("other")
+		c.So(qnet.WrapError("127.0.0.1:5903", other), ShouldEqual, other)
+	})
+
+	Convey("Test rejected sessions give permanent errors", t, func(c C) {
+		for _, code := range []quic.ErrorCode{qnet.AdmissionRejectedCode, qnet.AuthFailureCode, 0x142} {
+			var resetErr *qnet.PeerResetError
+			err := qnet.WrapError("127.0.0.1:5903", qerr.Error(qerr.ErrorCode(code), "rejected"))
+			c.So(errors.As(err, &resetErr), ShouldBeTrue)
+			c.So(resetErr.Temporary(), ShouldBeFalse)
+		}
+
+		var resetErr *qnet.PeerResetError
+		err := qnet.WrapError("127.0.0.1:5903", qerr.PublicReset)
+		c.So(errors.As(err, &resetErr), ShouldBeTrue)
+		c.So(resetErr.Temporary(), ShouldBeTrue)
+	})
+
+	Convey("Test malformed multiaddrs give permanent errors", t, func(c C) {
+		for _, target := range []string{"/ip4/127.0.0.1/sctp/5903", "/ip4/127.0.0.1"} {
+			_, _, err := qnet.ParseMultiaddr(mustMultiaddr(target))
+			c.So(err, ShouldNotBeNil)
+
+			var tmp temporary
+			c.So(errors.As(err, &tmp), ShouldBeTrue)
+			c.So(tmp.Temporary(), ShouldBeFalse)
+		}
+
+		var protocolErr *qnet.UnsupportedProtocolError
+		_, _, err := qnet.ParseMultiaddr(mustMultiaddr("/ip4/127.0.0.1/sctp/5903"))
+		c.So(errors.As(err, &protocolErr), ShouldBeTrue)
+		c.So(protocolErr.Protocol, ShouldEqual, "sctp")
+	})
+
+	Convey("Test dialing an unsupported protocol fails at once", t, func(c C) {
+		start := time.Now()
+		_, err := qgrpc.Dial("/ip4/127.0.0.1/sctp/5903",
+			opts.WithInsecure(), opts.WithBlock(), opts.FailOnNonTempDialError(true), opts.WithTimeout(5*time.Second))
+		c.So(err, ShouldNotBeNil)
+		c.So(time.Since(start), ShouldBeLessThan, 4*time.Second)
+	})
+
+	Convey("Test a silent peer gives a handshake timeout", t, func(c C) {
+		pconn, err := net.ListenPacket("udp", "127.0.0.1:5903")
+		c.So(err, ShouldBeNil)
+		defer pconn.Close()
+
+		_, err = quic.DialAddr("127.0.0.1:5903", &tls.Config{InsecureSkipVerify: true},
+			&quic.Config{HandshakeTimeout: 200 * time.Millisecond})
+		err = qnet.WrapError("127.0.0.1:5903", err)
+
+		var handshakeErr *qnet.HandshakeTimeoutError
+		c.So(errors.As(err, &handshakeErr), ShouldBeTrue)
+	})
+}
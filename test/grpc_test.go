@@ -6,8 +6,12 @@ import (
 	"crypto/rsa"
 	"crypto/tls"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/pem"
 	"math/big"
+	"net"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -17,6 +21,8 @@ import (
 	"github.com/gfanton/grpc-quic/proto/hello"
 	. "github.com/smartystreets/goconvey/convey"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/resolver"
 	"google.golang.org/grpc/resolver/manual"
 )
@@ -29,6 +35,17 @@ func (h *Hello) SayHello(ctx context.Context, in *hello.HelloRequest) (*hello.He
 	return rep, nil
 }
 
+// SlowHello answers like Hello after Delay
+type SlowHello struct {
+	Hello
+	Delay time.Duration
+}
+
+func (h *SlowHello) SayHello(ctx context.Context, in *hello.HelloRequest) (*hello.HelloReply, error) {
+	time.Sleep(h.Delay)
+	return h.Hello.SayHello(ctx, in)
+}
+
 // Setup a bare-bones TLS config for the server
 func generateTLSConfig() (*tls.Config, error) {
 	key, err := rsa.GenerateKey(rand.Reader, 1024)
@@ -52,6 +69,64 @@ func generateTLSConfig() (*tls.Config, error) {
 	return &tls.Config{Certificates: []tls.Certificate{tlsCert}}, nil
 }
 
+// testCA issues the certificates of tests verifying their peers.
+type testCA struct {
+	cert *x509.Certificate
+	key  *rsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCA() (*testCA, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool}, nil
+}
+
+// issue returns a certificate signed by ca from template, whose serial
+// number and validity are set.
+func (ca *testCA) issue(template *x509.Certificate) (tls.Certificate, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
 func testDial(t *testing.T, target string) {
 	var (
 		client *grpc.ClientConn
@@ -118,6 +193,21 @@ func TestDialTCP(t *testing.T) {
 	testDial(t, target)
 }
 
+func TestDialUDP6(t *testing.T) {
+	target := "/ip6/::1/udp/5848"
+	testDial(t, target)
+}
+
+func TestDialTCP6(t *testing.T) {
+	target := "/ip6/::1/tcp/5848"
+	testDial(t, target)
+}
+
+func TestDialDNS4(t *testing.T) {
+	target := "/dns4/localhost/udp/5849"
+	testDial(t, target)
+}
+
 type testHandler func(*manual.Resolver, hello.GreeterClient, []*grpc.Server)
 
 func testBalancerProgressiveClose(mresolver *manual.Resolver, client hello.GreeterClient, servers []*grpc.Server) {
@@ -154,6 +244,28 @@ func testBalancerDial(mresolver *manual.Resolver, client hello.GreeterClient, se
 	So(rep.GetMessage(), ShouldEqual, "Hello World")
 }
 
+func testBalancerPriority(mresolver *manual.Resolver, client hello.GreeterClient, servers []*grpc.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+
+	req := new(hello.HelloRequest)
+	req.Name = "World"
+
+	// Wait for the UDP subconn to be ready
+	var p peer.Peer
+	for p.Addr == nil || p.Addr.Network() != "udp" {
+		_, err := client.SayHello(ctx, req, grpc.Peer(&p))
+		So(err, ShouldBeNil)
+	}
+
+	for i := 0; i < 5; i++ {
+		rep, err := client.SayHello(ctx, req, grpc.Peer(&p))
+		So(err, ShouldBeNil)
+		So(rep.GetMessage(), ShouldEqual, "Hello World")
+		So(p.Addr.Network(), ShouldEqual, "udp")
+	}
+}
+
 func testBalancer(t *testing.T, balancerName string, clientAddrs []string, serverAddrs []string, handlers ...testHandler) {
 	var (
 		client    *grpc.ClientConn
@@ -279,3 +391,507 @@ func TestBalancerProgressiveClose(t *testing.T) {
 
 	testBalancer(t, balancerName, addrs, addrs, testBalancerProgressiveClose)
 }
+
+func TestBalancerPriority(t *testing.T) {
+	balancerName := quicbalancer.PriorityName
+	addrs := []string{
+		"/ip4/127.0.0.1/tcp/6858",
+		"/ip4/127.0.0.1/udp/6858",
+	}
+
+	testBalancer(t, balancerName, addrs, addrs, testBalancerPriority)
+}
+
+func TestBalancerLatency(t *testing.T) {
+	var (
+		client  *grpc.ClientConn
+		servers []*grpc.Server
+	)
+
+	defer func() {
+		if client != nil {
+			client.Close()
+		}
+
+		for _, server := range servers {
+			server.Stop()
+		}
+	}()
+
+	Convey("Setup a fast UDP server and a slow TCP server", t, func(c C) {
+		tlsConf, err := generateTLSConfig()
+		c.So(err, ShouldBeNil)
+
+		handlers := map[string]hello.GreeterServer{
+			"/ip4/127.0.0.1/udp/6859": &Hello{},
+			"/ip4/127.0.0.1/tcp/6859": &SlowHello{Delay: 20 * time.Millisecond},
+		}
+
+		for addr, h := range handlers {
+			s, l, err := qgrpc.NewServer(addr, opts.TLSConfig(tlsConf))
+			c.So(err, ShouldBeNil)
+
+			hello.RegisterGreeterServer(s, h)
+			servers = append(servers, s)
+
+			go s.Serve(l)
+		}
+	})
+
+	Convey("Test picks are weighted toward the fastest server", t, func(c C) {
+		tlsConf := &tls.Config{InsecureSkipVerify: true}
+
+		var err error
+		client, err = qgrpc.Dial("multiaddr:///ip4/127.0.0.1/tcp/6859,/ip4/127.0.0.1/udp/6859",
+			opts.WithTLSConfig(tlsConf),
+			opts.WithBalancerName(quicbalancer.LatencyName),
+		)
+		c.So(err, ShouldBeNil)
+
+		greet := hello.NewGreeterClient(client)
+		req := &hello.HelloRequest{Name: "World"}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// Wait for both subconns to be measured
+		seen := make(map[string]bool)
+		for len(seen) < 2 {
+			var p peer.Peer
+			_, err := greet.SayHello(ctx, req, grpc.Peer(&p))
+			c.So(err, ShouldBeNil)
+			seen[p.Addr.Network()] = true
+		}
+
+		udp := 0
+		for i := 0; i < 50; i++ {
+			var p peer.Peer
+			rep, err := greet.SayHello(ctx, req, grpc.Peer(&p))
+			c.So(err, ShouldBeNil)
+			c.So(rep.GetMessage(), ShouldEqual, "Hello World")
+
+			if p.Addr.Network() == "udp" {
+				udp++
+			}
+		}
+
+		c.So(udp, ShouldBeGreaterThan, 40)
+	})
+
+	Convey("Test stream interceptors can only be chained with the balancer", t, func(c C) {
+		interceptor := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+
+		raw := func(opt grpc.DialOption) opts.DialOption {
+			return func(o *opts.ClientConfig) error {
+				o.GrpcDialOptions = append(o.GrpcDialOptions, opt)
+				return nil
+			}
+		}
+
+		target := "/ip4/127.0.0.1/udp/6859"
+		for _, i := range []grpc.StreamClientInterceptor{interceptor, nil} {
+			_, err := qgrpc.Dial(target, raw(grpc.WithStreamInterceptor(i)))
+			c.So(err, ShouldEqual, qgrpc.ErrStreamInterceptor)
+		}
+
+		for _, opt := range []opts.DialOption{
+			opts.WithStreamInterceptor(interceptor),
+			raw(grpc.WithUnaryInterceptor(grpc.UnaryClientInterceptor(nil))),
+		} {
+			cc, err := qgrpc.Dial(target, opt)
+			c.So(err, ShouldBeNil)
+			cc.Close()
+		}
+	})
+}
+
+func TestBalancerBreaker(t *testing.T) {
+	var (
+		client  *grpc.ClientConn
+		servers []*grpc.Server
+	)
+
+	defer func() {
+		if client != nil {
+			client.Close()
+		}
+
+		for _, server := range servers {
+			server.Stop()
+		}
+	}()
+
+	balancer.Register(quicbalancer.NewBreakerBuilder("test_breaker_balancer", &quicbalancer.BreakerConfig{
+		Threshold: 1,
+		Cooldown:  500 * time.Millisecond,
+	}))
+
+	tlsConf, err := generateTLSConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(c C, addr string) {
+		s, l, err := qgrpc.NewServer(addr, opts.TLSConfig(tlsConf))
+		c.So(err, ShouldBeNil)
+
+		hello.RegisterGreeterServer(s, &Hello{})
+		servers = append(servers, s)
+
+		go s.Serve(l)
+	}
+
+	Convey("Setup TCP server", t, func(c C) {
+		serve(c, "/ip4/127.0.0.1/tcp/6860")
+	})
+
+	Convey("Test calls go over TCP while UDP is down", t, func(c C) {
+		tlsConf := &tls.Config{InsecureSkipVerify: true}
+
+		var err error
+		client, err = qgrpc.Dial("multiaddr:///ip4/127.0.0.1/udp/6860,/ip4/127.0.0.1/tcp/6860",
+			opts.WithTLSConfig(tlsConf),
+			opts.WithBalancerName("test_breaker_balancer"),
+		)
+		c.So(err, ShouldBeNil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		var p peer.Peer
+		_, err = hello.NewGreeterClient(client).SayHello(ctx, &hello.HelloRequest{Name: "World"}, grpc.Peer(&p))
+		c.So(err, ShouldBeNil)
+		c.So(p.Addr.Network(), ShouldEqual, "tcp")
+	})
+
+	Convey("Test UDP is promoted back once it works", t, func(c C) {
+		serve(c, "/ip4/127.0.0.1/udp/6860")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		greet := hello.NewGreeterClient(client)
+		var p peer.Peer
+		for p.Addr == nil || p.Addr.Network() != "udp" {
+			_, err := greet.SayHello(ctx, &hello.HelloRequest{Name: "World"}, grpc.Peer(&p))
+			c.So(err, ShouldBeNil)
+			time.Sleep(50 * time.Millisecond)
+		}
+	})
+}
+
+// udpRelay forwards the datagrams of its clients to a UDP server, each from
+// its own socket, and drops them all while blackholed.
+type udpRelay struct {
+	conn     *net.UDPConn
+	upstream *net.UDPAddr
+	blocked  int32
+
+	mu    sync.Mutex
+	peers map[string]*net.UDPConn
+}
+
+func newUDPRelay(addr, upstream string) (*udpRelay, error) {
+	laddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	raddr, err := net.ResolveUDPAddr("udp", upstream)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp", laddr)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &udpRelay{conn: conn, upstream: raddr, peers: make(map[string]*net.UDPConn)}
+	go r.serve()
+	return r, nil
+}
+
+func (r *udpRelay) blackhole(on bool) {
+	v := int32(0)
+	if on {
+		v = 1
+	}
+
+	atomic.StoreInt32(&r.blocked, v)
+}
+
+func (r *udpRelay) serve() {
+	buf := make([]byte, 2048)
+	for {
+		n, client, err := r.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+
+		if atomic.LoadInt32(&r.blocked) == 1 {
+			continue
+		}
+
+		r.mu.Lock()
+		peer, ok := r.peers[client.String()]
+		if !ok {
+			peer, err = net.DialUDP("udp", nil, r.upstream)
+			if err != nil {
+				r.mu.Unlock()
+				continue
+			}
+
+			r.peers[client.String()] = peer
+			go r.reply(peer, client)
+		}
+		r.mu.Unlock()
+
+		peer.Write(buf[:n])
+	}
+}
+
+func (r *udpRelay) reply(peer *net.UDPConn, client *net.UDPAddr) {
+	buf := make([]byte, 2048)
+	for {
+		n, err := peer.Read(buf)
+		if err != nil {
+			return
+		}
+
+		if atomic.LoadInt32(&r.blocked) == 0 {
+			r.conn.WriteToUDP(buf[:n], client)
+		}
+	}
+}
+
+func (r *udpRelay) Close() {
+	r.conn.Close()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, peer := range r.peers {
+		peer.Close()
+	}
+}
+
+func TestBalancerBreakerProbe(t *testing.T) {
+	var (
+		client  *grpc.ClientConn
+		relay   *udpRelay
+		servers []*grpc.Server
+	)
+
+	defer func() {
+		if client != nil {
+			client.Close()
+		}
+
+		if relay != nil {
+			relay.Close()
+		}
+
+		for _, server := range servers {
+			server.Stop()
+		}
+	}()
+
+	balancer.Register(quicbalancer.NewBreakerBuilder("test_breaker_probe_balancer", &quicbalancer.BreakerConfig{
+		Threshold:    2,
+		Cooldown:     300 * time.Millisecond,
+		ProbeTimeout: 300 * time.Millisecond,
+	}))
+
+	Convey("Setup UDP and TCP servers, with a relay in front of UDP", t, func(c C) {
+		tlsConf, err := generateTLSConfig()
+		c.So(err, ShouldBeNil)
+
+		for _, addr := range []string{"/ip4/127.0.0.1/udp/6862", "/ip4/127.0.0.1/tcp/6861"} {
+			s, l, err := qgrpc.NewServer(addr, opts.TLSConfig(tlsConf))
+			c.So(err, ShouldBeNil)
+
+			hello.RegisterGreeterServer(s, &Hello{})
+			servers = append(servers, s)
+
+			go s.Serve(l)
+		}
+
+		relay, err = newUDPRelay("127.0.0.1:6861", "127.0.0.1:6862")
+		c.So(err, ShouldBeNil)
+	})
+
+	greet := func(timeout time.Duration) (network string, err error) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		var p peer.Peer
+		_, err = hello.NewGreeterClient(client).SayHello(ctx, &hello.HelloRequest{Name: "World"}, grpc.Peer(&p))
+		if p.Addr != nil {
+			network = p.Addr.Network()
+		}
+
+		return network, err
+	}
+
+	Convey("Test calls go over UDP while QUIC works", t, func(c C) {
+		tlsConf := &tls.Config{InsecureSkipVerify: true}
+
+		var err error
+		client, err = qgrpc.Dial("multiaddr:///ip4/127.0.0.1/udp/6861,/ip4/127.0.0.1/tcp/6861",
+			opts.WithTLSConfig(tlsConf),
+			opts.WithBalancerName("test_breaker_probe_balancer"),
+		)
+		c.So(err, ShouldBeNil)
+
+		deadline := time.Now().Add(5 * time.Second)
+		network := ""
+		for network != "udp" && time.Now().Before(deadline) {
+			network, err = greet(time.Second)
+			c.So(err, ShouldBeNil)
+		}
+
+		c.So(network, ShouldEqual, "udp")
+	})
+
+	Convey("Test a blackholed UDP subconn is demoted after the threshold", t, func(c C) {
+		relay.blackhole(true)
+
+		failures := 0
+		for i := 0; i < 2; i++ {
+			if _, err := greet(100 * time.Millisecond); err != nil {
+				failures++
+			}
+		}
+		c.So(failures, ShouldEqual, 2)
+
+		// no call is sacrificed to probe UDP, even past the cooldown
+		for i := 0; i < 10; i++ {
+			network, err := greet(time.Second)
+			c.So(err, ShouldBeNil)
+			c.So(network, ShouldEqual, "tcp")
+
+			time.Sleep(100 * time.Millisecond)
+		}
+	})
+
+	Convey("Test UDP is promoted back after a successful probe", t, func(c C) {
+		relay.blackhole(false)
+
+		deadline := time.Now().Add(5 * time.Second)
+		network := ""
+		for network != "udp" && time.Now().Before(deadline) {
+			var err error
+			network, err = greet(time.Second)
+			c.So(err, ShouldBeNil)
+
+			time.Sleep(50 * time.Millisecond)
+		}
+
+		c.So(network, ShouldEqual, "udp")
+	})
+}
+
+func TestBalancerBreakerHappyEyeballs(t *testing.T) {
+	var (
+		client  *grpc.ClientConn
+		relay   *udpRelay
+		servers []*grpc.Server
+	)
+
+	defer func() {
+		if client != nil {
+			client.Close()
+		}
+
+		if relay != nil {
+			relay.Close()
+		}
+
+		for _, server := range servers {
+			server.Stop()
+		}
+	}()
+
+	balancer.Register(quicbalancer.NewBreakerBuilder("test_breaker_eyeballs_balancer", &quicbalancer.BreakerConfig{
+		Threshold:    2,
+		Cooldown:     200 * time.Millisecond,
+		ProbeTimeout: time.Second,
+	}))
+
+	Convey("Setup UDP and TCP servers, with a relay in front of UDP", t, func(c C) {
+		tlsConf, err := generateTLSConfig()
+		c.So(err, ShouldBeNil)
+
+		for _, addr := range []string{"/ip4/127.0.0.1/udp/5918", "/ip4/127.0.0.1/tcp/5917"} {
+			s, l, err := qgrpc.NewServer(addr, opts.TLSConfig(tlsConf))
+			c.So(err, ShouldBeNil)
+
+			hello.RegisterGreeterServer(s, &Hello{})
+			servers = append(servers, s)
+
+			go s.Serve(l)
+		}
+
+		relay, err = newUDPRelay("127.0.0.1:5917", "127.0.0.1:5918")
+		c.So(err, ShouldBeNil)
+	})
+
+	greet := func(timeout time.Duration) (network string, err error) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		var p peer.Peer
+		_, err = hello.NewGreeterClient(client).SayHello(ctx, &hello.HelloRequest{Name: "World"}, grpc.Peer(&p))
+		if p.Addr != nil {
+			network = p.Addr.Network()
+		}
+
+		return network, err
+	}
+
+	Convey("Test calls go over UDP while QUIC works", t, func(c C) {
+		tlsConf := &tls.Config{InsecureSkipVerify: true}
+
+		var err error
+		client, err = qgrpc.Dial("multiaddr:///ip4/127.0.0.1/udp/5917,/ip4/127.0.0.1/tcp/5917",
+			opts.WithTLSConfig(tlsConf),
+			opts.WithHappyEyeballs(100*time.Millisecond),
+			opts.WithBalancerName("test_breaker_eyeballs_balancer"),
+		)
+		c.So(err, ShouldBeNil)
+
+		deadline := time.Now().Add(5 * time.Second)
+		network := ""
+		for network != "udp" && time.Now().Before(deadline) {
+			network, err = greet(time.Second)
+			c.So(err, ShouldBeNil)
+		}
+
+		c.So(network, ShouldEqual, "udp")
+	})
+
+	Convey("Test probes falling back to TCP keep UDP demoted", t, func(c C) {
+		relay.blackhole(true)
+
+		failures := 0
+		for i := 0; i < 2; i++ {
+			if _, err := greet(100 * time.Millisecond); err != nil {
+				failures++
+			}
+		}
+		c.So(failures, ShouldEqual, 2)
+
+		// the probes past the cooldown connect over TCP with happy
+		// eyeballs, a promotion would send calls to the blackholed subconn
+		for i := 0; i < 15; i++ {
+			network, err := greet(time.Second)
+			c.So(err, ShouldBeNil)
+			c.So(network, ShouldEqual, "tcp")
+
+			time.Sleep(100 * time.Millisecond)
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	qgrpc "github.com/gfanton/grpc-quic"
+	"github.com/gfanton/grpc-quic/opts"
+	"github.com/gfanton/grpc-quic/proto/hello"
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+func TestInsecure(t *testing.T) {
+	var server *qgrpc.Server
+
+	defer func() {
+		if server != nil {
+			server.Stop()
+		}
+	}()
+
+	Convey("Setup insecure server without any certificate", t, func(c C) {
+		var err error
+		server, err = qgrpc.New(opts.Insecure())
+		c.So(err, ShouldBeNil)
+
+		hello.RegisterGreeterServer(server.Server, &Hello{})
+		go server.ListenAndServe("/ip4/127.0.0.1/udp/5896", "/ip4/127.0.0.1/tcp/5896")
+	})
+
+	Convey("Test insecure dial", t, func(c C) {
+		for _, target := range []string{"/ip4/127.0.0.1/udp/5896", "/ip4/127.0.0.1/tcp/5896"} {
+			client, err := qgrpc.Dial(target, opts.WithInsecure())
+			c.So(err, ShouldBeNil)
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			var p peer.Peer
+			rep, err := hello.NewGreeterClient(client).SayHello(ctx, &hello.HelloRequest{Name: "World"}, grpc.Peer(&p))
+			cancel()
+			client.Close()
+
+			c.So(err, ShouldBeNil)
+			c.So(rep.GetMessage(), ShouldEqual, "Hello World")
+
+			// TCP connections are plaintext
+			if p.Addr.Network() == "tcp" {
+				c.So(p.AuthInfo, ShouldBeNil)
+			}
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package test
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+
+	qgrpc "github.com/gfanton/grpc-quic"
+	qnet "github.com/gfanton/grpc-quic/net"
+	"github.com/gfanton/grpc-quic/opts"
+	"github.com/gfanton/grpc-quic/proto/hello"
+	quic "github.com/lucas-clemente/quic-go"
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/grpc"
+)
+
+func TestListenerIdleSession(t *testing.T) {
+	var (
+		client *grpc.ClientConn
+		server *grpc.Server
+		idle   quic.Session
+	)
+
+	defer func() {
+		if idle != nil {
+			idle.Close()
+		}
+
+		if client != nil {
+			client.Close()
+		}
+
+		if server != nil {
+			server.Stop()
+		}
+	}()
+
+	target := "/ip4/127.0.0.1/udp/5861"
+	dropped := make(chan error, 1)
+
+	Convey("Setup server", t, func(c C) {
+		tlsConf, err := generateTLSConfig()
+		c.So(err, ShouldBeNil)
+
+		s, l, err := qgrpc.NewServer(target,
+			opts.TLSConfig(tlsConf),
+			opts.AcceptTimeout(500*time.Millisecond),
+			opts.AcceptErrorHandler(func(_ net.Addr, err error) {
+				dropped <- err
+			}),
+		)
+		c.So(err, ShouldBeNil)
+
+		hello.RegisterGreeterServer(s, &Hello{})
+		server = s
+
+		go func() {
+			err := s.Serve(l)
+			c.So(err, ShouldBeNil)
+		}()
+	})
+
+	Convey("Open a session without any stream", t, func(c C) {
+		tlsConf := &tls.Config{InsecureSkipVerify: true}
+
+		var err error
+		idle, err = quic.DialAddr("127.0.0.1:5861", tlsConf, nil)
+		c.So(err, ShouldBeNil)
+	})
+
+	Convey("Test dial while a session is idle", t, func(c C) {
+		tlsConf := &tls.Config{InsecureSkipVerify: true}
+
+		var err error
+		client, err = qgrpc.Dial(target, opts.WithTLSConfig(tlsConf))
+		c.So(err, ShouldBeNil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+		defer cancel()
+
+		rep, err := hello.NewGreeterClient(client).SayHello(ctx, &hello.HelloRequest{Name: "World"})
+		c.So(err, ShouldBeNil)
+		c.So(rep.GetMessage(), ShouldEqual, "Hello World")
+	})
+
+	Convey("Test idle session is dropped", t, func(c C) {
+		select {
+		case err := <-dropped:
+			c.So(err, ShouldEqual, qnet.ErrAcceptTimeout)
+		case <-time.After(time.Second):
+			c.So("timeout", ShouldBeEmpty)
+		}
+
+		_, err := idle.AcceptStream()
+		code, ok := qnet.CloseCode(qnet.WrapError("127.0.0.1:5861", err))
+		c.So(ok, ShouldBeTrue)
+		c.So(code, ShouldEqual, qnet.IdleCode)
+	})
+}
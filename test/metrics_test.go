@@ -0,0 +1,268 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	qgrpc "github.com/gfanton/grpc-quic"
+	quicmetrics "github.com/gfanton/grpc-quic/metrics"
+	qnet "github.com/gfanton/grpc-quic/net"
+	"github.com/gfanton/grpc-quic/opts"
+	"github.com/gfanton/grpc-quic/proto/hello"
+	ma "github.com/multiformats/go-multiaddr"
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/grpc"
+)
+
+// sample returns the value of the sample name{labels} written by c, ok is
+// false if there is none.
+func sample(c *quicmetrics.Collector, name, labels string) (v float64, ok bool) {
+	var buf bytes.Buffer
+	if _, err := c.WriteTo(&buf); err != nil {
+		return 0, false
+	}
+
+	prefix := name + "{" + labels + "} "
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, prefix) {
+			v, err := strconv.ParseFloat(strings.TrimPrefix(line, prefix), 64)
+			return v, err == nil
+		}
+	}
+
+	return 0, false
+}
+
+func TestMetrics(t *testing.T) {
+	var (
+		server  *qgrpc.Server
+		clients []*grpc.ClientConn
+	)
+
+	defer func() {
+		for _, client := range clients {
+			client.Close()
+		}
+
+		if server != nil {
+			server.Stop()
+		}
+	}()
+
+	serverMetrics := quicmetrics.NewCollector()
+	clientMetrics := quicmetrics.NewCollector()
+
+	var certLen int
+
+	Convey("Setup server with metrics", t, func(c C) {
+		tlsConf, err := generateTLSConfig()
+		c.So(err, ShouldBeNil)
+
+		certLen = len(tlsConf.Certificates[0].Certificate[0])
+
+		server, err = qgrpc.New(opts.TLSConfig(tlsConf), opts.Metrics(serverMetrics))
+		c.So(err, ShouldBeNil)
+
+		hello.RegisterGreeterServer(server.Server, &Hello{})
+		for _, laddr := range []string{"/ip4/127.0.0.1/udp/5908", "/ip4/127.0.0.1/tcp/5908"} {
+			l, err := server.Listen(laddr)
+			c.So(err, ShouldBeNil)
+			go server.Serve(l)
+		}
+	})
+
+	Convey("Test connections are measured per protocol", t, func(c C) {
+		for _, target := range []string{"/ip4/127.0.0.1/udp/5908", "/ip4/127.0.0.1/tcp/5908"} {
+			client, err := qgrpc.Dial(target,
+				opts.WithTLSConfig(&tls.Config{InsecureSkipVerify: true}),
+				opts.WithMetrics(clientMetrics),
+				opts.WithBlock(), opts.WithTimeout(time.Second),
+			)
+			c.So(err, ShouldBeNil)
+			clients = append(clients, client)
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			_, err = hello.NewGreeterClient(client).SayHello(ctx, &hello.HelloRequest{Name: "World"})
+			cancel()
+			c.So(err, ShouldBeNil)
+		}
+
+		for _, protocol := range []string{quicmetrics.ProtocolQUIC, quicmetrics.ProtocolTCP} {
+			target := "/ip4/127.0.0.1/udp/5908"
+			if protocol == quicmetrics.ProtocolTCP {
+				target = "/ip4/127.0.0.1/tcp/5908"
+			}
+
+			listener := fmt.Sprintf(`role="listener",addr="127.0.0.1:5908",protocol="%s"`, protocol)
+			dialer := fmt.Sprintf(`role="dialer",addr="%s",protocol="%s"`, target, protocol)
+
+			for _, name := range []string{"grpcquic_bytes_read_total", "grpcquic_bytes_written_total"} {
+				v, ok := sample(serverMetrics, name, listener)
+				c.So(ok, ShouldBeTrue)
+				c.So(v, ShouldBeGreaterThan, 0)
+
+				v, ok = sample(clientMetrics, name, dialer)
+				c.So(ok, ShouldBeTrue)
+				c.So(v, ShouldBeGreaterThan, 0)
+			}
+
+			// the bytes are counted above TLS, so the certificate sent
+			// in the handshake is not
+			v, _ := sample(serverMetrics, "grpcquic_bytes_written_total", listener)
+			c.So(v, ShouldBeLessThan, certLen)
+
+			v, ok := sample(serverMetrics, "grpcquic_active_sessions", listener)
+			c.So(ok, ShouldBeTrue)
+			c.So(v, ShouldEqual, 1)
+
+			v, ok = sample(clientMetrics, "grpcquic_active_sessions", dialer)
+			c.So(ok, ShouldBeTrue)
+			c.So(v, ShouldEqual, 1)
+
+			v, ok = sample(clientMetrics, "grpcquic_handshake_seconds_count", dialer)
+			c.So(ok, ShouldBeTrue)
+			c.So(v, ShouldEqual, 1)
+
+			v, ok = sample(clientMetrics, "grpcquic_handshake_seconds_bucket", dialer+`,le="+Inf"`)
+			c.So(ok, ShouldBeTrue)
+			c.So(v, ShouldEqual, 1)
+
+			// the TLS handshake of TCP, and the QUIC handshake from the
+			// first packet of the client
+			v, ok = sample(serverMetrics, "grpcquic_handshake_seconds_count", listener)
+			c.So(ok, ShouldBeTrue)
+			c.So(v, ShouldEqual, 1)
+
+			v, ok = sample(serverMetrics, "grpcquic_handshake_seconds_sum", listener)
+			c.So(ok, ShouldBeTrue)
+			c.So(v, ShouldBeGreaterThan, 0)
+		}
+	})
+
+	Convey("Test closed sessions report their lifetime", t, func(c C) {
+		for _, client := range clients {
+			client.Close()
+		}
+		clients = nil
+
+		dialer := `role="dialer",addr="/ip4/127.0.0.1/udp/5908",protocol="udp/quic"`
+		listener := `role="listener",addr="127.0.0.1:5908",protocol="udp/quic"`
+
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if v, _ := sample(serverMetrics, "grpcquic_active_sessions", listener); v == 0 {
+				break
+			}
+
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		v, ok := sample(serverMetrics, "grpcquic_session_lifetime_seconds_count", listener)
+		c.So(ok, ShouldBeTrue)
+		c.So(v, ShouldEqual, 1)
+
+		v, _ = sample(clientMetrics, "grpcquic_active_sessions", dialer)
+		c.So(v, ShouldEqual, 0)
+
+		v, ok = sample(clientMetrics, "grpcquic_session_lifetime_seconds_count", dialer)
+		c.So(ok, ShouldBeTrue)
+		c.So(v, ShouldEqual, 1)
+	})
+
+	Convey("Test failed TLS handshakes are not metered as sessions", t, func(c C) {
+		other, err := generateTLSConfig()
+		c.So(err, ShouldBeNil)
+
+		cert, err := x509.ParseCertificate(other.Certificates[0].Certificate[0])
+		c.So(err, ShouldBeNil)
+
+		base, err := ma.NewMultiaddr("/ip4/127.0.0.1/tcp/5908")
+		c.So(err, ShouldBeNil)
+
+		// the TLS handshake of a pinned address is run by the dialer
+		wrong, err := qnet.WithCertHash(base, cert)
+		c.So(err, ShouldBeNil)
+
+		_, err = qgrpc.Dial(wrong.String(),
+			opts.WithMetrics(clientMetrics),
+			opts.WithBlock(), opts.WithTimeout(500*time.Millisecond),
+			opts.FailOnNonTempDialError(true),
+		)
+		c.So(err, ShouldNotBeNil)
+
+		dialer := fmt.Sprintf(`role="dialer",addr="%s",protocol="tcp"`, wrong)
+		_, ok := sample(clientMetrics, "grpcquic_dial_failures_total", dialer+`,error="other"`)
+		c.So(ok, ShouldBeTrue)
+
+		_, ok = sample(clientMetrics, "grpcquic_active_sessions", dialer)
+		c.So(ok, ShouldBeFalse)
+
+		_, ok = sample(clientMetrics, "grpcquic_session_lifetime_seconds_count", dialer)
+		c.So(ok, ShouldBeFalse)
+	})
+
+	Convey("Test dial failures are counted by error type", t, func(c C) {
+		client, err := qgrpc.Dial("/ip4/127.0.0.1/tcp/5909",
+			opts.WithTLSConfig(&tls.Config{InsecureSkipVerify: true}),
+			opts.WithMetrics(clientMetrics),
+		)
+		c.So(err, ShouldBeNil)
+		clients = append(clients, client)
+
+		labels := `role="dialer",addr="/ip4/127.0.0.1/tcp/5909",protocol="tcp",error="network"`
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if _, ok := sample(clientMetrics, "grpcquic_dial_failures_total", labels); ok {
+				break
+			}
+
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		v, ok := sample(clientMetrics, "grpcquic_dial_failures_total", labels)
+		c.So(ok, ShouldBeTrue)
+		c.So(v, ShouldBeGreaterThanOrEqualTo, 1)
+	})
+}
+
+func TestMetricsCollectorConcurrency(t *testing.T) {
+	Convey("Test the collector counts bytes from concurrent connections", t, func(c C) {
+		collector := quicmetrics.NewCollector()
+		labels := quicmetrics.Labels{Role: quicmetrics.RoleListener, Addr: "127.0.0.1:5908", Protocol: quicmetrics.ProtocolTCP}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 1000; j++ {
+					collector.BytesRead(labels, 1)
+					collector.BytesWritten(labels, 2)
+				}
+			}()
+		}
+
+		// snapshots are taken while the counters are updated
+		var buf bytes.Buffer
+		_, err := collector.WriteTo(&buf)
+		c.So(err, ShouldBeNil)
+
+		wg.Wait()
+
+		v, ok := sample(collector, "grpcquic_bytes_read_total", `role="listener",addr="127.0.0.1:5908",protocol="tcp"`)
+		c.So(ok, ShouldBeTrue)
+		c.So(v, ShouldEqual, 8000)
+
+		v, ok = sample(collector, "grpcquic_bytes_written_total", `role="listener",addr="127.0.0.1:5908",protocol="tcp"`)
+		c.So(ok, ShouldBeTrue)
+		c.So(v, ShouldEqual, 16000)
+	})
+}
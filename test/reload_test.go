@@ -0,0 +1,187 @@
+package test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	qgrpc "github.com/gfanton/grpc-quic"
+	"github.com/gfanton/grpc-quic/opts"
+	"github.com/gfanton/grpc-quic/proto/hello"
+	"github.com/gfanton/grpc-quic/transports"
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/grpc"
+)
+
+// writeServerCert writes a self-signed certificate for 127.0.0.1 and its key
+// in dir, the certificate is its own CA.
+func writeServerCert(dir string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		return err
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := ioutil.WriteFile(filepath.Join(dir, "cert.pem"), certPEM, 0600); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, "key.pem"), keyPEM, 0600)
+}
+
+func TestTLSReload(t *testing.T) {
+	var (
+		server    *qgrpc.Server
+		serverSrc *transports.FileSource
+		clientSrc *transports.FileSource
+		quicConn  *grpc.ClientConn
+	)
+
+	dir, err := ioutil.TempDir("", "grpc-quic-reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if quicConn != nil {
+			quicConn.Close()
+		}
+		if server != nil {
+			server.Stop()
+		}
+		if serverSrc != nil {
+			serverSrc.Close()
+		}
+		if clientSrc != nil {
+			clientSrc.Close()
+		}
+		os.RemoveAll(dir)
+	}()
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	events := make(chan transports.ReloadEvent, 16)
+
+	sayHello := func(cc *grpc.ClientConn) error {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		_, err := hello.NewGreeterClient(cc).SayHello(ctx, &hello.HelloRequest{Name: "World"})
+		return err
+	}
+
+	dialHello := func(target string) error {
+		cc, err := qgrpc.Dial(target, opts.WithTLSSource(clientSrc))
+		if err != nil {
+			return err
+		}
+		defer cc.Close()
+
+		return sayHello(cc)
+	}
+
+	Convey("Setup server and client sources", t, func(c C) {
+		c.So(writeServerCert(dir), ShouldBeNil)
+
+		serverSrc, err = transports.NewFileSource(&transports.FileSourceConfig{
+			CertFile: certFile,
+			KeyFile:  keyFile,
+			Interval: 50 * time.Millisecond,
+			OnReload: func(ev transports.ReloadEvent) { events <- ev },
+		})
+		c.So(err, ShouldBeNil)
+
+		// the server certificate is its own CA
+		clientSrc, err = transports.NewFileSource(&transports.FileSourceConfig{
+			CAFile:   certFile,
+			Interval: time.Hour,
+		})
+		c.So(err, ShouldBeNil)
+
+		server, err = qgrpc.New(opts.TLSSource(serverSrc))
+		c.So(err, ShouldBeNil)
+
+		hello.RegisterGreeterServer(server.Server, &Hello{})
+		go server.ListenAndServe("/ip4/127.0.0.1/udp/5900", "/ip4/127.0.0.1/tcp/5900")
+	})
+
+	Convey("Test clients verify the server with the CA file", t, func(c C) {
+		c.So(dialHello("/ip4/127.0.0.1/udp/5900"), ShouldBeNil)
+		c.So(dialHello("/ip4/127.0.0.1/tcp/5900"), ShouldBeNil)
+
+		quicConn, err = qgrpc.Dial("/ip4/127.0.0.1/udp/5900", opts.WithTLSSource(clientSrc))
+		c.So(err, ShouldBeNil)
+		c.So(sayHello(quicConn), ShouldBeNil)
+	})
+
+	Convey("Test the server reloads a new certificate", t, func(c C) {
+		c.So(writeServerCert(dir), ShouldBeNil)
+
+		select {
+		case ev := <-events:
+			c.So(ev.Err, ShouldBeNil)
+		case <-time.After(2 * time.Second):
+			c.So("no reload event", ShouldBeEmpty)
+		}
+
+		// clients trusting the old certificate are refused
+		c.So(dialHello("/ip4/127.0.0.1/tcp/5900"), ShouldNotBeNil)
+
+		// established sessions keep running
+		c.So(sayHello(quicConn), ShouldBeNil)
+
+		c.So(clientSrc.Reload(), ShouldBeNil)
+		c.So(dialHello("/ip4/127.0.0.1/udp/5900"), ShouldBeNil)
+		c.So(dialHello("/ip4/127.0.0.1/tcp/5900"), ShouldBeNil)
+	})
+
+	Convey("Test a failed reload keeps the current certificate", t, func(c C) {
+		c.So(ioutil.WriteFile(keyFile, []byte("garbage"), 0600), ShouldBeNil)
+
+		select {
+		case ev := <-events:
+			c.So(ev.Err, ShouldNotBeNil)
+		case <-time.After(2 * time.Second):
+			c.So("no reload event", ShouldBeEmpty)
+		}
+
+		c.So(dialHello("/ip4/127.0.0.1/tcp/5900"), ShouldBeNil)
+	})
+}
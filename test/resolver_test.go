@@ -0,0 +1,192 @@
+package test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"testing"
+	"time"
+
+	qgrpc "github.com/gfanton/grpc-quic"
+	quicbalancer "github.com/gfanton/grpc-quic/balancer"
+	"github.com/gfanton/grpc-quic/opts"
+	"github.com/gfanton/grpc-quic/proto/hello"
+	madns "github.com/multiformats/go-multiaddr-dns"
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+func TestResolverDNSAddr(t *testing.T) {
+	var (
+		ca      *testCA
+		client  *grpc.ClientConn
+		servers = make(map[string]*grpc.Server)
+	)
+
+	defer func() {
+		if client != nil {
+			client.Close()
+		}
+
+		for _, server := range servers {
+			server.Stop()
+		}
+	}()
+
+	backend := &madns.MockBackend{
+		TXT: map[string][]string{
+			"_dnsaddr.example.com": {
+				"dnsaddr=/dnsaddr/udp.example.com",
+				"dnsaddr=/ip4/127.0.0.1/udp/5919",
+				"dnsaddr=/ip4/127.0.0.1/tcp/5871",
+			},
+			"_dnsaddr.udp.example.com": {
+				"dnsaddr=/dns4/backend.example.com/udp/5870",
+			},
+		},
+		IP: map[string][]net.IPAddr{
+			"backend.example.com": {{IP: net.ParseIP("127.0.0.1")}},
+		},
+	}
+
+	Convey("Setup servers with a certificate for the dnsaddr domain", t, func(c C) {
+		var err error
+		ca, err = newTestCA()
+		c.So(err, ShouldBeNil)
+
+		// the /ip4 entries are verified for the dnsaddr domain, the /dns4
+		// one for its own hostname
+		cert, err := ca.issue(&x509.Certificate{
+			Subject:     pkix.Name{CommonName: "example.com"},
+			DNSNames:    []string{"example.com", "backend.example.com"},
+			KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		})
+		c.So(err, ShouldBeNil)
+
+		tlsConf := &tls.Config{Certificates: []tls.Certificate{cert}}
+		// servers are keyed by the network and address of their peers
+		listeners := map[string]string{
+			"udp 127.0.0.1:5870": "/ip4/127.0.0.1/udp/5870",
+			"udp 127.0.0.1:5919": "/ip4/127.0.0.1/udp/5919",
+			"tcp 127.0.0.1:5871": "/ip4/127.0.0.1/tcp/5871",
+		}
+
+		for addr, m := range listeners {
+			s, l, err := qgrpc.NewServer(m, opts.TLSConfig(tlsConf))
+			c.So(err, ShouldBeNil)
+
+			hello.RegisterGreeterServer(s, &Hello{})
+			servers[addr] = s
+
+			go s.Serve(l)
+		}
+	})
+
+	Convey("Setup client", t, func(c C) {
+		tlsConf := &tls.Config{RootCAs: ca.pool}
+
+		var err error
+		client, err = qgrpc.Dial("/dnsaddr/example.com",
+			opts.WithTLSConfig(tlsConf),
+			opts.WithBalancerName(quicbalancer.Name),
+			opts.WithDNSResolver(backend),
+		)
+		c.So(err, ShouldBeNil)
+	})
+
+	Convey("Test calls reach every resolved address", t, func(c C) {
+		greet := hello.NewGreeterClient(client)
+		req := &hello.HelloRequest{Name: "World"}
+
+		answered := make(map[string]bool)
+		for range servers {
+			var p peer.Peer
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			rep, err := greet.SayHello(ctx, req, grpc.Peer(&p))
+			cancel()
+
+			c.So(err, ShouldBeNil)
+			c.So(rep.GetMessage(), ShouldEqual, "Hello World")
+			c.So(p.Addr, ShouldNotBeNil)
+
+			addr := p.Addr.Network() + " " + p.Addr.String()
+			c.So(servers, ShouldContainKey, addr)
+			c.So(answered[addr], ShouldBeFalse)
+			answered[addr] = true
+
+			// the next call must go through a remaining server
+			servers[addr].Stop()
+			time.Sleep(500 * time.Millisecond)
+		}
+
+		c.So(answered, ShouldResemble, map[string]bool{
+			"udp 127.0.0.1:5870": true,
+			"udp 127.0.0.1:5919": true,
+			"tcp 127.0.0.1:5871": true,
+		})
+	})
+}
+
+func TestResolverMultiaddr(t *testing.T) {
+	var (
+		client *grpc.ClientConn
+		server *grpc.Server
+	)
+
+	defer func() {
+		if client != nil {
+			client.Close()
+		}
+
+		if server != nil {
+			server.Stop()
+		}
+	}()
+
+	Convey("Setup server", t, func(c C) {
+		tlsConf, err := generateTLSConfig()
+		c.So(err, ShouldBeNil)
+
+		s, l, err := qgrpc.NewServer("/ip4/127.0.0.1/udp/5872", opts.TLSConfig(tlsConf))
+		c.So(err, ShouldBeNil)
+
+		hello.RegisterGreeterServer(s, &Hello{})
+		server = s
+
+		go func() {
+			err := s.Serve(l)
+			c.So(err, ShouldBeNil)
+		}()
+	})
+
+	Convey("Test invalid multiaddrs", t, func(c C) {
+		tlsConf := &tls.Config{InsecureSkipVerify: true}
+
+		_, err := qgrpc.Dial("multiaddr:///ip4/127.0.0.1/udp/5872,/ip4/127.0.0.1/sctp/5872",
+			opts.WithTLSConfig(tlsConf),
+		)
+		c.So(err, ShouldNotBeNil)
+	})
+
+	Convey("Test dial", t, func(c C) {
+		tlsConf := &tls.Config{InsecureSkipVerify: true}
+
+		var err error
+		client, err = qgrpc.Dial("multiaddr:///ip4/127.0.0.1/tcp/5873,/ip4/127.0.0.1/udp/5872",
+			opts.WithTLSConfig(tlsConf),
+			opts.WithBalancerName(quicbalancer.Name),
+		)
+		c.So(err, ShouldBeNil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		rep, err := hello.NewGreeterClient(client).SayHello(ctx, &hello.HelloRequest{Name: "World"})
+		c.So(err, ShouldBeNil)
+		c.So(rep.GetMessage(), ShouldEqual, "Hello World")
+	})
+}
@@ -0,0 +1,138 @@
+package test
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	qgrpc "github.com/gfanton/grpc-quic"
+	"github.com/gfanton/grpc-quic/opts"
+	"github.com/gfanton/grpc-quic/proto/hello"
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/grpc"
+)
+
+func TestServer(t *testing.T) {
+	var (
+		server *qgrpc.Server
+		calls  int32
+	)
+
+	defer func() {
+		if server != nil {
+			server.Stop()
+		}
+	}()
+
+	serveErr := make(chan error, 1)
+
+	Convey("Setup server on UDP and TCP", t, func(c C) {
+		tlsConf, err := generateTLSConfig()
+		c.So(err, ShouldBeNil)
+
+		count := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			return handler(ctx, req)
+		}
+
+		server, err = qgrpc.New(opts.TLSConfig(tlsConf), opts.UnaryInterceptor(count))
+		c.So(err, ShouldBeNil)
+
+		hello.RegisterGreeterServer(server.Server, &Hello{})
+
+		go func() {
+			serveErr <- server.ListenAndServe("/ip4/127.0.0.1/udp/5894", "/ip4/127.0.0.1/tcp/5894")
+		}()
+	})
+
+	Convey("Test calls over every multiaddr go through the options", t, func(c C) {
+		tlsConf := &tls.Config{InsecureSkipVerify: true}
+
+		for i, target := range []string{"/ip4/127.0.0.1/udp/5894", "/ip4/127.0.0.1/tcp/5894"} {
+			client, err := qgrpc.Dial(target, opts.WithTLSConfig(tlsConf))
+			c.So(err, ShouldBeNil)
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			rep, err := hello.NewGreeterClient(client).SayHello(ctx, &hello.HelloRequest{Name: "World"})
+			cancel()
+			client.Close()
+
+			c.So(err, ShouldBeNil)
+			c.So(rep.GetMessage(), ShouldEqual, "Hello World")
+			c.So(atomic.LoadInt32(&calls), ShouldEqual, i+1)
+		}
+	})
+
+	Convey("Test stop releases the UDP socket", t, func(c C) {
+		server.Stop()
+		c.So(<-serveErr, ShouldBeNil)
+
+		addr, err := net.ResolveUDPAddr("udp4", "127.0.0.1:5894")
+		c.So(err, ShouldBeNil)
+
+		pconn, err := net.ListenUDP("udp4", addr)
+		c.So(err, ShouldBeNil)
+		pconn.Close()
+	})
+}
+
+func TestServerGracefulStopNativeStreams(t *testing.T) {
+	var (
+		server *qgrpc.Server
+		client *grpc.ClientConn
+	)
+
+	defer func() {
+		if client != nil {
+			client.Close()
+		}
+
+		if server != nil {
+			server.Stop()
+		}
+	}()
+
+	Convey("Setup server", t, func(c C) {
+		tlsConf, err := generateTLSConfig()
+		c.So(err, ShouldBeNil)
+
+		server, err = qgrpc.New(opts.TLSConfig(tlsConf), opts.NativeStreams())
+		c.So(err, ShouldBeNil)
+
+		hello.RegisterGreeterServer(server.Server, &SlowHello{Delay: 200 * time.Millisecond})
+		go server.ListenAndServe("/ip4/127.0.0.1/udp/5895")
+	})
+
+	Convey("Test graceful stop waits for calls in flight", t, func(c C) {
+		var err error
+		client, err = qgrpc.Dial("/ip4/127.0.0.1/udp/5895",
+			opts.WithTLSConfig(&tls.Config{InsecureSkipVerify: true}),
+			opts.WithNativeStreams(),
+		)
+		c.So(err, ShouldBeNil)
+
+		type result struct {
+			rep *hello.HelloReply
+			err error
+		}
+
+		done := make(chan result, 1)
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			rep, err := hello.NewGreeterClient(client).SayHello(ctx, &hello.HelloRequest{Name: "World"})
+			done <- result{rep, err}
+		}()
+
+		time.Sleep(100 * time.Millisecond)
+		server.GracefulStop()
+
+		res := <-done
+		c.So(res.err, ShouldBeNil)
+		c.So(res.rep.GetMessage(), ShouldEqual, "Hello World")
+	})
+}
@@ -0,0 +1,105 @@
+package test
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"testing"
+	"time"
+
+	qgrpc "github.com/gfanton/grpc-quic"
+	"github.com/gfanton/grpc-quic/opts"
+	"github.com/gfanton/grpc-quic/proto/hello"
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type helloResult struct {
+	rep *hello.HelloReply
+	err error
+}
+
+// sayHelloAsync calls SayHello in the background.
+func sayHelloAsync(client *grpc.ClientConn) <-chan helloResult {
+	done := make(chan helloResult, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		rep, err := hello.NewGreeterClient(client).SayHello(ctx, &hello.HelloRequest{Name: "World"})
+		done <- helloResult{rep, err}
+	}()
+
+	return done
+}
+
+func TestServerShutdown(t *testing.T) {
+	var clients []*grpc.ClientConn
+
+	defer func() {
+		for _, client := range clients {
+			client.Close()
+		}
+	}()
+
+	tlsConf, err := generateTLSConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(c C, target string, delay time.Duration) (*qgrpc.Server, *grpc.ClientConn, <-chan error) {
+		server, err := qgrpc.New(opts.TLSConfig(tlsConf))
+		c.So(err, ShouldBeNil)
+
+		hello.RegisterGreeterServer(server.Server, &SlowHello{Delay: delay})
+
+		serveErr := make(chan error, 1)
+		go func() {
+			serveErr <- server.ListenAndServe(target)
+		}()
+
+		client, err := qgrpc.Dial(target, opts.WithTLSConfig(&tls.Config{InsecureSkipVerify: true}))
+		c.So(err, ShouldBeNil)
+		clients = append(clients, client)
+
+		return server, client, serveErr
+	}
+
+	Convey("Test shutdown waits for calls in flight", t, func(c C) {
+		server, client, serveErr := serve(c, "/ip4/127.0.0.1/udp/5905", 300*time.Millisecond)
+
+		done := sayHelloAsync(client)
+		time.Sleep(100 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		c.So(server.Shutdown(ctx), ShouldBeNil)
+
+		res := <-done
+		c.So(res.err, ShouldBeNil)
+		c.So(res.rep.GetMessage(), ShouldEqual, "Hello World")
+		c.So(<-serveErr, ShouldBeNil)
+	})
+
+	Convey("Test shutdown closes the sessions left after the deadline", t, func(c C) {
+		server, client, serveErr := serve(c, "/ip4/127.0.0.1/udp/5906", 3*time.Second)
+
+		done := sayHelloAsync(client)
+		time.Sleep(100 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		err := server.Shutdown(ctx)
+		c.So(errors.Is(err, context.DeadlineExceeded), ShouldBeTrue)
+		c.So(time.Since(start), ShouldBeLessThan, 2*time.Second)
+
+		res := <-done
+		c.So(status.Code(res.err), ShouldEqual, codes.Unavailable)
+		c.So(<-serveErr, ShouldBeNil)
+	})
+}
@@ -0,0 +1,107 @@
+package test
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	qgrpc "github.com/gfanton/grpc-quic"
+	"github.com/gfanton/grpc-quic/opts"
+	"github.com/gfanton/grpc-quic/proto/hello"
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestNativeStreams(t *testing.T) {
+	var (
+		client *grpc.ClientConn
+		server *grpc.Server
+	)
+
+	defer func() {
+		if client != nil {
+			client.Close()
+		}
+
+		if server != nil {
+			server.Stop()
+		}
+	}()
+
+	target := "/ip4/127.0.0.1/udp/5860"
+
+	Convey("Setup server", t, func(c C) {
+		tlsConf, err := generateTLSConfig()
+		c.So(err, ShouldBeNil)
+
+		s, l, err := qgrpc.NewServer(target, opts.TLSConfig(tlsConf), opts.NativeStreams())
+		c.So(err, ShouldBeNil)
+
+		hello.RegisterGreeterServer(s, &Hello{})
+		server = s
+
+		go func() {
+			err := s.Serve(l)
+			c.So(err, ShouldBeNil)
+		}()
+	})
+
+	Convey("Setup client", t, func(c C) {
+		tlsConf := &tls.Config{InsecureSkipVerify: true}
+
+		var err error
+		client, err = qgrpc.Dial(target, opts.WithTLSConfig(tlsConf), opts.WithNativeStreams())
+		c.So(err, ShouldBeNil)
+	})
+
+	Convey("Test concurrent calls", t, func(c C) {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		greet := hello.NewGreeterClient(client)
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 20)
+		for i := 0; i < cap(errs); i++ {
+			wg.Add(1)
+			go func(name string) {
+				defer wg.Done()
+
+				rep, err := greet.SayHello(ctx, &hello.HelloRequest{Name: name})
+				if err == nil && rep.GetMessage() != "Hello "+name {
+					err = fmt.Errorf("unexpected reply `%s`", rep.GetMessage())
+				}
+				errs <- err
+			}(fmt.Sprintf("World %d", i))
+		}
+
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			c.So(err, ShouldBeNil)
+		}
+	})
+	Convey("Test header lists larger than 16KB are rejected", t, func(c C) {
+		greet := hello.NewGreeterClient(client)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-large", strings.Repeat("a", 20<<10))
+		_, err := greet.SayHello(ctx, &hello.HelloRequest{Name: "World"})
+		cancel()
+		c.So(err, ShouldNotBeNil)
+
+		// only the call is aborted, not the session
+		ctx, cancel = context.WithTimeout(context.Background(), 2*time.Second)
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-small", strings.Repeat("a", 1<<10))
+		rep, err := greet.SayHello(ctx, &hello.HelloRequest{Name: "World"})
+		cancel()
+		c.So(err, ShouldBeNil)
+		c.So(rep.GetMessage(), ShouldEqual, "Hello World")
+	})
+}
@@ -0,0 +1,59 @@
+package transports
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"math/big"
+	"time"
+)
+
+// ephemeralCertValidity is the validity of ephemeral certificates, long
+// enough for any process using one.
+const ephemeralCertValidity = 365 * 24 * time.Hour
+
+// EphemeralTLSConfig returns a server TLS config with a freshly generated
+// self-signed certificate, and the SHA-256 fingerprint of that certificate.
+// It is meant for insecure mode, where QUIC still requires TLS but peers do
+// not verify certificates.
+func EphemeralTLSConfig() (*tls.Config, string, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, "", err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "grpc-quic ephemeral"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(ephemeralCertValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	sum := sha256.Sum256(der)
+	tlsConf := &tls.Config{
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{der},
+			PrivateKey:  key,
+		}},
+	}
+
+	return tlsConf, hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,262 @@
+package transports
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/grpclog"
+)
+
+// DefaultReloadInterval is the default interval at which a FileSource checks
+// whether its files changed.
+const DefaultReloadInterval = 10 * time.Second
+
+// FileSourceConfig configures a FileSource.
+type FileSourceConfig struct {
+	// CertFile and KeyFile are the PEM encoded certificate chain and private
+	// key, used by servers and as client certificate. Both may be empty for
+	// clients without a certificate.
+	CertFile string
+	KeyFile  string
+
+	// CAFile is an optional PEM encoded CA bundle, which verifies servers for
+	// clients, and the certificates given by clients for servers. Clients
+	// use the system roots if it is empty.
+	CAFile string
+
+	// Interval is the interval at which the files are checked for changes.
+	// DefaultReloadInterval is used if it is zero.
+	Interval time.Duration
+
+	// OnReload, if set, is called after every reload with its outcome.
+	OnReload func(ReloadEvent)
+}
+
+// ReloadEvent is the outcome of a reload of a FileSource.
+type ReloadEvent struct {
+	Time time.Time
+
+	// Err is nil if the files were reloaded. Otherwise, the source keeps
+	// what it loaded last.
+	Err error
+}
+
+// FileSource provides TLS configs backed by certificate, key and CA files,
+// which are reloaded when they change. The configs get the current
+// certificates on every handshake, so established connections are left as
+// is and new ones use the new certificates.
+type FileSource struct {
+	cfg FileSourceConfig
+
+	mu     sync.Mutex
+	cert   *tls.Certificate
+	pool   *x509.CertPool
+	stamps map[string]fileStamp
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func (s fileStamp) equal(o fileStamp) bool {
+	return s.modTime.Equal(o.modTime) && s.size == o.size
+}
+
+// NewFileSource loads the files of cfg and watches them until Close. It
+// fails if the files cannot be loaded the first time.
+func NewFileSource(cfg *FileSourceConfig) (*FileSource, error) {
+	if cfg == nil || (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return nil, errors.New("both a certificate and a key file are required")
+	}
+
+	s := &FileSource{
+		cfg:  *cfg,
+		done: make(chan struct{}),
+	}
+
+	if s.cfg.Interval <= 0 {
+		s.cfg.Interval = DefaultReloadInterval
+	}
+
+	s.stamps = s.stat()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	go s.watch()
+	return s, nil
+}
+
+// Reload loads the files again, whether they changed or not.
+func (s *FileSource) Reload() error {
+	s.mu.Lock()
+	s.stamps = s.stat()
+	s.mu.Unlock()
+
+	err := s.load()
+	s.publish(err)
+	return err
+}
+
+// Close stops watching the files. The configs keep using what was loaded
+// last.
+func (s *FileSource) Close() error {
+	s.closeOnce.Do(func() { close(s.done) })
+	return nil
+}
+
+// ServerTLSConfig returns a server TLS config presenting the current
+// certificate. With a CA file, the certificates given by clients over TCP
+// are verified against the current bundle. QUIC listeners do not request
+// client certificates from a source.
+func (s *FileSource) ServerTLSConfig() *tls.Config {
+	conf := &tls.Config{GetCertificate: s.getCertificate}
+	if s.cfg.CAFile == "" {
+		return conf
+	}
+
+	conf.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		return &tls.Config{
+			GetCertificate: s.getCertificate,
+			ClientAuth:     tls.VerifyClientCertIfGiven,
+			ClientCAs:      s.pool,
+			NextProtos:     []string{"h2"},
+		}, nil
+	}
+
+	return conf
+}
+
+// ClientTLSConfig returns a client TLS config verifying servers against the
+// current CA bundle, and presenting the current certificate if any. The CA
+// bundle is read once by the config, a new one must be taken for every
+// connection.
+func (s *FileSource) ClientTLSConfig() *tls.Config {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conf := &tls.Config{RootCAs: s.pool}
+	if s.cfg.CertFile != "" {
+		conf.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return s.getCertificate(nil)
+		}
+	}
+
+	return conf
+}
+
+func (s *FileSource) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cert == nil {
+		return nil, errors.New("no certificate")
+	}
+
+	return s.cert, nil
+}
+
+func (s *FileSource) load() error {
+	var cert *tls.Certificate
+	if s.cfg.CertFile != "" {
+		c, err := tls.LoadX509KeyPair(s.cfg.CertFile, s.cfg.KeyFile)
+		if err != nil {
+			return err
+		}
+		cert = &c
+	}
+
+	var pool *x509.CertPool
+	if s.cfg.CAFile != "" {
+		data, err := ioutil.ReadFile(s.cfg.CAFile)
+		if err != nil {
+			return err
+		}
+
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no certificate found in `%s`", s.cfg.CAFile)
+		}
+	}
+
+	s.mu.Lock()
+	s.cert, s.pool = cert, pool
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *FileSource) publish(err error) {
+	if err != nil {
+		grpclog.Warningf("transports: unable to reload TLS files: %v", err)
+	} else {
+		grpclog.Infof("transports: TLS files reloaded")
+	}
+
+	if s.cfg.OnReload != nil {
+		s.cfg.OnReload(ReloadEvent{Time: time.Now(), Err: err})
+	}
+}
+
+// stat returns the stamps of the files, missing files have an empty stamp.
+func (s *FileSource) stat() map[string]fileStamp {
+	stamps := make(map[string]fileStamp, 3)
+	for _, path := range []string{s.cfg.CertFile, s.cfg.KeyFile, s.cfg.CAFile} {
+		if path == "" {
+			continue
+		}
+
+		var stamp fileStamp
+		if fi, err := os.Stat(path); err == nil {
+			stamp = fileStamp{modTime: fi.ModTime(), size: fi.Size()}
+		}
+		stamps[path] = stamp
+	}
+
+	return stamps
+}
+
+// watch reloads the files every time one of them changes. The new stamps
+// are kept even if the reload fails, files being written one after the
+// other, the next change triggers another reload.
+func (s *FileSource) watch() {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+
+		stamps := s.stat()
+
+		s.mu.Lock()
+		changed := false
+		for path, stamp := range stamps {
+			if !stamp.equal(s.stamps[path]) {
+				changed = true
+			}
+		}
+		s.stamps = stamps
+		s.mu.Unlock()
+
+		if changed {
+			s.publish(s.load())
+		}
+	}
+}
@@ -4,38 +4,132 @@ import (
 	"context"
 	"crypto/tls"
 	"net"
+	"strings"
+	"sync"
 
 	quicnet "github.com/gfanton/grpc-quic/net"
+	ma "github.com/multiformats/go-multiaddr"
+	madns "github.com/multiformats/go-multiaddr-dns"
 	"google.golang.org/grpc/credentials"
 )
 
-var _ credentials.AuthInfo = (*Info)(nil)
+const (
+	// QuicProtocolVersion is the wire protocol version of gRPC over QUIC.
+	QuicProtocolVersion = "/quic/1.0.0"
 
-// Info contains the auth information
-type Info struct {
-	conn *quicnet.Conn
+	// QuicSecurityProtocol is the security protocol of QUIC connections.
+	QuicSecurityProtocol = "quic-tls"
+)
+
+// quicInfo returns the auth information of a QUIC connection. It is a
+// credentials.TLSInfo like the one of a TLS connection over TCP, so the
+// peers of both can be handled alike, see TLSState.
+func quicInfo(c quicnet.SessionConn) credentials.TLSInfo {
+	return credentials.TLSInfo{State: quicnet.ConnectionState(c.Session())}
+}
+
+// TLSState returns the TLS state of the connection described by ai, which
+// is either a QUIC connection or a TLS connection over TCP, including calls
+// served on native QUIC streams. ok is false for insecure connections.
+func TLSState(ai credentials.AuthInfo) (state tls.ConnectionState, ok bool) {
+	if info, ok := ai.(credentials.TLSInfo); ok {
+		return info.State, true
+	}
+
+	return
+}
+
+// ProtocolInfo returns the protocol and security information of the
+// connection described by ai, as given by the peer of a call. Unlike the
+// Info method of Credentials, it describes this connection only. QUIC
+// connections, including calls served on native QUIC streams, are told
+// apart by their state, which never has a cipher suite since QUIC does not
+// expose it. gQUIC versions handshake with QUIC crypto rather than TLS,
+// their SecurityVersion is "gQUIC".
+func ProtocolInfo(ai credentials.AuthInfo) credentials.ProtocolInfo {
+	info, ok := ai.(credentials.TLSInfo)
+	if !ok {
+		return credentials.ProtocolInfo{SecurityProtocol: "insecure"}
+	}
+
+	if info.State.CipherSuite != 0 {
+		return credentials.ProtocolInfo{
+			SecurityProtocol: "tls",
+			SecurityVersion:  tlsVersion(info.State.Version),
+			ServerName:       info.State.ServerName,
+		}
+	}
+
+	pinfo := credentials.ProtocolInfo{
+		ProtocolVersion:  QuicProtocolVersion,
+		SecurityProtocol: QuicSecurityProtocol,
+		SecurityVersion:  tlsVersion(info.State.Version),
+		ServerName:       info.State.ServerName,
+	}
+
+	if pinfo.SecurityVersion == "" {
+		pinfo.SecurityVersion = "gQUIC"
+	}
+
+	return pinfo
 }
 
-func NewInfo(c *quicnet.Conn) *Info {
-	return &Info{c}
+func tlsVersion(v uint16) string {
+	switch v {
+	case tls.VersionTLS10:
+		return "1.0"
+	case tls.VersionTLS11:
+		return "1.1"
+	case tls.VersionTLS12:
+		return "1.2"
+	case tls.VersionTLS13:
+		return "1.3"
+	}
+
+	return ""
 }
 
-// AuthType returns the type of Info as a string.
-func (i *Info) AuthType() string {
-	return "quic-tls"
+// TLSConn is a TLS connection whose handshake was already done by the
+// dialer, Credentials hand it over to gRPC as is. Conn is either the TLS
+// connection or a wrapper of it, such as a metered connection.
+type TLSConn struct {
+	net.Conn
+
+	// State is the state of the TLS connection after its handshake.
+	State tls.ConnectionState
 }
 
-func (i *Info) Conn() net.Conn {
-	return i.conn
+// ClientTLSConfig returns a copy of tlsConf negotiating HTTP/2, as gRPC
+// requires over TLS.
+func ClientTLSConfig(tlsConf *tls.Config) *tls.Config {
+	if tlsConf == nil {
+		tlsConf = &tls.Config{}
+	} else {
+		tlsConf = tlsConf.Clone()
+	}
+
+	for _, p := range tlsConf.NextProtos {
+		if p == "h2" {
+			return tlsConf
+		}
+	}
+
+	tlsConf.NextProtos = append(tlsConf.NextProtos, "h2")
+	return tlsConf
 }
 
 var _ credentials.TransportCredentials = (*Credentials)(nil)
 
+// Credentials are the transport credentials of both QUIC and TCP
+// connections. They are safe to share, the information of a connection is
+// held by its AuthInfo, see ProtocolInfo.
 type Credentials struct {
-	tlsConfig        *tls.Config
-	isQuicConnection bool
-	serverName       string
+	tlsConfig *tls.Config
+
+	mu         sync.Mutex
+	serverName string
 
+	// grpcCreds is nil in insecure mode
 	grpcCreds credentials.TransportCredentials
 }
 
@@ -47,6 +141,13 @@ func NewCredentials(tlsConfig *tls.Config) credentials.TransportCredentials {
 	}
 }
 
+// NewInsecureCredentials returns credentials leaving TCP connections in
+// plaintext. QUIC connections are always encrypted, but their certificates
+// are not verified in insecure mode.
+func NewInsecureCredentials() credentials.TransportCredentials {
+	return &Credentials{}
+}
+
 // ClientHandshake does the authentication handshake specified by the corresponding
 // authentication protocol on rawConn for clients. It returns the authenticated
 // connection and the corresponding auth information about the connection.
@@ -58,12 +159,58 @@ func NewCredentials(tlsConfig *tls.Config) credentials.TransportCredentials {
 //
 // If the returned net.Conn is closed, it MUST close the net.Conn provided.
 func (pt *Credentials) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
-	if c, ok := conn.(*quicnet.Conn); ok {
-		pt.isQuicConnection = true
-		return conn, NewInfo(c), nil
+	if c, ok := conn.(quicnet.SessionConn); ok {
+		return conn, quicInfo(c), nil
+	}
+
+	if c, ok := conn.(*TLSConn); ok {
+		return conn, credentials.TLSInfo{State: c.State}, nil
+	}
+
+	if pt.grpcCreds == nil {
+		return conn, nil, nil
+	}
+
+	// gRPC uses the dial target as authority, which is a multiaddr or a list
+	// of them
+	if host, ok := multiaddrHost(authority); ok {
+		authority = host
+	}
+
+	// metered connections are measured above TLS
+	var info credentials.AuthInfo
+	conn, err := quicnet.MeterHandshake(conn, func(conn net.Conn) (net.Conn, error) {
+		secure, ai, err := pt.grpcCreds.ClientHandshake(ctx, authority, conn)
+		info = ai
+		return secure, err
+	})
+
+	return conn, info, err
+}
+
+// multiaddrHost returns the host of the first multiaddr of authority, or the
+// domain of a /dnsaddr authority.
+func multiaddrHost(authority string) (string, bool) {
+	if i := strings.IndexByte(authority, ','); i >= 0 {
+		authority = authority[:i]
+	}
+
+	m, err := ma.NewMultiaddr("/" + strings.TrimPrefix(authority, "/"))
+	if err != nil {
+		return "", false
 	}
 
-	return pt.grpcCreds.ClientHandshake(ctx, authority, conn)
+	// the addresses of a /dnsaddr target are verified for its domain
+	if domain, err := m.ValueForProtocol(madns.DnsaddrProtocol.Code); err == nil {
+		return domain, true
+	}
+
+	host, err := quicnet.Hostname(m)
+	if err != nil {
+		return "", false
+	}
+
+	return host, true
 }
 
 // ServerHandshake does the authentication handshake for servers. It returns
@@ -72,27 +219,35 @@ func (pt *Credentials) ClientHandshake(ctx context.Context, authority string, co
 //
 // If the returned net.Conn is closed, it MUST close the net.Conn provided.
 func (pt *Credentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
-	if c, ok := conn.(*quicnet.Conn); ok {
-		pt.isQuicConnection = true
-		ainfo := NewInfo(c)
-		return conn, ainfo, nil
+	if c, ok := conn.(quicnet.SessionConn); ok {
+		return conn, quicInfo(c), nil
 	}
 
-	return pt.grpcCreds.ServerHandshake(conn)
+	if pt.grpcCreds == nil {
+		return conn, nil, nil
+	}
+
+	var info credentials.AuthInfo
+	conn, err := quicnet.MeterHandshake(conn, func(conn net.Conn) (net.Conn, error) {
+		secure, ai, err := pt.grpcCreds.ServerHandshake(conn)
+		info = ai
+		return secure, err
+	})
+
+	return conn, info, err
 }
 
-// Info provides the ProtocolInfo of this Credentials.
+// Info provides the ProtocolInfo of this Credentials. It does not depend on
+// the connections handshaked, use ProtocolInfo with the AuthInfo of a
+// connection to know how it is secured.
 func (pt *Credentials) Info() credentials.ProtocolInfo {
-	if pt.isQuicConnection {
+	if pt.grpcCreds == nil {
+		pt.mu.Lock()
+		defer pt.mu.Unlock()
+
 		return credentials.ProtocolInfo{
-			// ProtocolVersion is the gRPC wire protocol version.
-			ProtocolVersion: "/quic/1.0.0",
-			// SecurityProtocol is the security protocol in use.
-			SecurityProtocol: "quic-tls",
-			// SecurityVersion is the security protocol version.
-			SecurityVersion: "1.2.0",
-			// ServerName is the user-configured server name.
-			ServerName: pt.serverName,
+			SecurityProtocol: "insecure",
+			ServerName:       pt.serverName,
 		}
 	}
 
@@ -101,9 +256,17 @@ func (pt *Credentials) Info() credentials.ProtocolInfo {
 
 // Clone makes a copy of this Credentials.
 func (pt *Credentials) Clone() credentials.TransportCredentials {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	if pt.grpcCreds == nil {
+		return &Credentials{serverName: pt.serverName}
+	}
+
 	return &Credentials{
-		tlsConfig: pt.tlsConfig.Clone(),
-		grpcCreds: pt.grpcCreds.Clone(),
+		tlsConfig:  pt.tlsConfig.Clone(),
+		serverName: pt.serverName,
+		grpcCreds:  pt.grpcCreds.Clone(),
 	}
 }
 
@@ -111,6 +274,13 @@ func (pt *Credentials) Clone() credentials.TransportCredentials {
 // gRPC internals also use it to override the virtual hosting name if it is set.
 // It must be called before dialing. Currently, this is only used by grpclb.
 func (pt *Credentials) OverrideServerName(name string) error {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
 	pt.serverName = name
+	if pt.grpcCreds == nil {
+		return nil
+	}
+
 	return pt.grpcCreds.OverrideServerName(name)
 }